@@ -0,0 +1,60 @@
+package accounts
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"marmalade/config"
+	"marmalade/helpers"
+)
+
+var (
+	AlreadyRegisteredError = errors.New("accounts: already registered")
+	NotRegisteredError     = errors.New("accounts: not registered")
+
+	mu     = new(sync.Mutex)
+	hashes = map[string]string{} // lowercase username to password hash
+)
+
+// Initialize loads the accounts from disk
+func Initialize() {
+	if err := helpers.LoadJSON(config.AccountsPath, &hashes); err != nil {
+		panic(err)
+	}
+}
+
+func Registered(username string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	_, found := hashes[strings.ToLower(username)]
+	return found
+}
+
+// Register saves a new account, failing with AlreadyRegisteredError if the username has one
+func Register(username, password string) error {
+	hash, hashErr := HashPassword(password)
+	if hashErr != nil {
+		return hashErr
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	key := strings.ToLower(username)
+	if _, found := hashes[key]; found {
+		return AlreadyRegisteredError
+	}
+	hashes[key] = hash
+	return helpers.SaveJSON(config.AccountsPath, hashes)
+}
+
+// Login checks the password of an account, failing with NotRegisteredError if the username has none
+func Login(username, password string) (bool, error) {
+	mu.Lock()
+	hash, found := hashes[strings.ToLower(username)]
+	mu.Unlock()
+	if !found {
+		return false, NotRegisteredError
+	}
+	return CheckPassword(password, hash)
+}
@@ -0,0 +1,27 @@
+package accounts
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"marmalade/config"
+)
+
+func TestNamesDifferingInCase(t *testing.T) {
+	defer func(old string) { config.AccountsPath = old }(config.AccountsPath)
+	config.AccountsPath = filepath.Join(t.TempDir(), "accounts.json")
+
+	if err := Register("Notch", "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register("notch", "hunter3"); !errors.Is(err, AlreadyRegisteredError) {
+		t.Fatalf("registered a name differing only in case: %v", err)
+	}
+	if !Registered("NOTCH") {
+		t.Fatal("the account wasn't found with another case")
+	}
+	if ok, err := Login("nOtCh", "hunter2"); !ok || err != nil {
+		t.Fatalf("couldn't log in with another case: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package accounts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	hashPrefix     = "pbkdf2-sha256"
+	hashIterations = 100000
+	hashSaltLength = 16
+	hashKeyLength  = 32
+)
+
+var InvalidHashError = errors.New("accounts: invalid password hash")
+
+// HashPassword returns a salted PBKDF2-HMAC-SHA256 hash in the form pbkdf2-sha256$iterations$salt$key
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, hashSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := pbkdf2([]byte(password), salt, hashIterations, hashKeyLength)
+	return fmt.Sprintf("%v$%v$%v$%v", hashPrefix, hashIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether the password matches a hash made by HashPassword
+func CheckPassword(password, hash string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != hashPrefix {
+		return false, InvalidHashError
+	}
+	iterations, iterationsErr := strconv.Atoi(parts[1])
+	if iterationsErr != nil || iterations <= 0 {
+		return false, InvalidHashError
+	}
+	salt, saltErr := base64.RawStdEncoding.DecodeString(parts[2])
+	if saltErr != nil {
+		return false, InvalidHashError
+	}
+	key, keyErr := base64.RawStdEncoding.DecodeString(parts[3])
+	if keyErr != nil {
+		return false, InvalidHashError
+	}
+	got := pbkdf2([]byte(password), salt, iterations, len(key))
+	return subtle.ConstantTimeCompare(got, key) == 1, nil
+}
+
+// PBKDF2 as in RFC 8018, with HMAC-SHA256 as the pseudorandom function
+func pbkdf2(password, salt []byte, iterations, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	out := make([]byte, 0, keyLength)
+	u := make([]byte, 0, sha256.Size)
+	for block := uint32(1); len(out) < keyLength; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		var blockBS [4]byte
+		binary.BigEndian.PutUint32(blockBS[:], block)
+		prf.Write(blockBS[:])
+		u = prf.Sum(u[:0])
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLength]
+}
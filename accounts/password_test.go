@@ -0,0 +1,29 @@
+package accounts
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// test vector from RFC 7914, section 11
+func TestPBKDF2(t *testing.T) {
+	got := hex.EncodeToString(pbkdf2([]byte("passwd"), []byte("salt"), 1, 64))
+	expected := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got != expected {
+		t.Fatalf("got %v, expected %v", got, expected)
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := CheckPassword("hunter2", hash); !ok || err != nil {
+		t.Fatalf("correct password rejected: %v", err)
+	}
+	if ok, _ := CheckPassword("hunter3", hash); ok {
+		t.Fatal("wrong password accepted")
+	}
+}
@@ -0,0 +1,18 @@
+package auth
+
+const MaxUsernameLength = 16
+
+// ValidUsername reports whether the username is 1 to 16 letters, digits, underscores or periods
+func ValidUsername(username string) bool {
+	if len(username) == 0 || len(username) > MaxUsernameLength {
+		return false
+	}
+	for _, c := range username {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidUsername(t *testing.T) {
+	for _, c := range []struct {
+		username string
+		expected bool
+	}{
+		{"", false},
+		{"a", true},
+		{strings.Repeat("a", MaxUsernameLength), true},
+		{strings.Repeat("a", MaxUsernameLength+1), false},
+		{"Notch", true},
+		{"notch", true},
+		{"NOTCH", true},
+		{"jeb_", true},
+		{"Mr.Builder99", true},
+		{"two words", false},
+		{"semi;colon", false},
+		{"dash-name", false},
+		{"&cred", false},
+		{"café", false},
+		{strings.Repeat("é", 8), false}, // 8 runes but 16 bytes
+		{"tab\t", false},
+	} {
+		if got := ValidUsername(c.username); got != c.expected {
+			t.Errorf("ValidUsername(%q) = %v, expected %v", c.username, got, c.expected)
+		}
+	}
+}
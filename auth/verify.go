@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"strings"
+)
+
+// Verify checks the verification key a client sent against md5(salt + username), as done by classic server lists
+func Verify(salt, username, key string) bool {
+	sum := md5.Sum([]byte(salt + username))
+	expected := hex.EncodeToString(sum[:])
+	// keys are sent as lowercase hex by some clients and uppercase by others, and may be padded with leading zeroes dropped
+	key = strings.ToLower(strings.TrimLeft(key, "0"))
+	expected = strings.TrimLeft(expected, "0")
+	return subtle.ConstantTimeCompare([]byte(key), []byte(expected)) == 1
+}
+
+// ParseNetworks parses a list of CIDRs or single IPs
+func ParseNetworks(list []string) ([]*net.IPNet, error) {
+	out := make([]*net.IPNet, 0, len(list))
+	for _, v := range list {
+		n, err := ParseNetwork(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// ParseNetwork parses a CIDR, or a single IP as a network only containing itself
+func ParseNetwork(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: s}
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	return n, err
+}
+
+// AddrIP returns the IP of a TCP address, or nil if there is none
+func AddrIP(addr net.Addr) net.IP {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// InNetworks reports whether the IP is in any of the networks
+func InNetworks(ip net.IP, networks []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, v := range networks {
+		if v.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package auth
+
+import "testing"
+
+func TestVerify(t *testing.T) {
+	// md5("wo6kVAHjxoJcInKxnotch") and md5("salt11notch"), the latter starting with a zero
+	for _, c := range []struct {
+		salt, key string
+		expected  bool
+	}{
+		{"wo6kVAHjxoJcInKx", "ebd0b03f74c13a8204b6a58481efee59", true},
+		{"wo6kVAHjxoJcInKx", "EBD0B03F74C13A8204B6A58481EFEE59", true},
+		{"wo6kVAHjxoJcInKx", "ebd0b03f74c13a8204b6a58481efee5", false},
+		{"wo6kVAHjxoJcInKx", "0ebd0b03f74c13a8204b6a58481efee59", true},
+		{"salt11", "0cf73a253794055225483008b796a5ed", true},
+		{"salt11", "cf73a253794055225483008b796a5ed", true},
+		{"salt11", "CF73A253794055225483008B796A5ED", true},
+		{"salt11", "ebd0b03f74c13a8204b6a58481efee59", false},
+		{"salt11", "", false},
+	} {
+		if got := Verify(c.salt, "notch", c.key); got != c.expected {
+			t.Errorf("Verify(%v, notch, %v) = %v, expected %v", c.salt, c.key, got, c.expected)
+		}
+	}
+	if Verify("salt11", "Notch", "0cf73a253794055225483008b796a5ed") {
+		t.Error("the username's case should matter")
+	}
+}
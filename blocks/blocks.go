@@ -0,0 +1,86 @@
+// Package blocks names the block types of Minecraft Classic
+package blocks
+
+import "fmt"
+
+const (
+	Air byte = iota
+	Stone
+	Grass
+	Dirt
+	Cobblestone
+	Planks
+	Sapling
+	Bedrock
+	FlowingWater
+	Water
+	FlowingLava
+	Lava
+	Sand
+	Gravel
+	GoldOre
+	IronOre
+	CoalOre
+	Log
+	Leaves
+	Sponge
+	Glass
+	RedWool
+	OrangeWool
+	YellowWool
+	LimeWool
+	GreenWool
+	AquaGreenWool
+	CyanWool
+	BlueWool
+	PurpleWool
+	IndigoWool
+	VioletWool
+	MagentaWool
+	PinkWool
+	BlackWool
+	GrayWool
+	WhiteWool
+	Dandelion
+	Rose
+	BrownMushroom
+	RedMushroom
+	GoldBlock
+	IronBlock
+	DoubleSlab
+	Slab
+	Bricks
+	TNT
+	Bookshelf
+	MossyCobblestone
+	Obsidian
+
+	// Count is the number of block types, every block type is less than it
+	Count = iota
+)
+
+var names = [Count]string{
+	"air", "stone", "grass", "dirt", "cobblestone", "planks", "sapling", "bedrock", "flowing water", "water",
+	"flowing lava", "lava", "sand", "gravel", "gold ore", "iron ore", "coal ore", "log", "leaves", "sponge",
+	"glass", "red wool", "orange wool", "yellow wool", "lime wool", "green wool", "aqua green wool", "cyan wool", "blue wool", "purple wool",
+	"indigo wool", "violet wool", "magenta wool", "pink wool", "black wool", "gray wool", "white wool", "dandelion", "rose", "brown mushroom",
+	"red mushroom", "gold block", "iron block", "double slab", "slab", "bricks", "TNT", "bookshelf", "mossy cobblestone", "obsidian",
+}
+
+// Name returns the name of the block type, or its number if it is unknown
+func Name(b byte) string {
+	if int(b) < len(names) {
+		return names[b]
+	}
+	return fmt.Sprintf("block %v", b)
+}
+
+// BlocksLight reports whether the block type casts a shadow on the blocks below it
+// Air, glass, leaves and plants let light through
+func BlocksLight(b byte) bool {
+	switch b {
+	case Air, Glass, Leaves, Sapling, Dandelion, Rose, BrownMushroom, RedMushroom:
+		return false
+	}
+	return true
+}
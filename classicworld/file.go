@@ -0,0 +1,102 @@
+package classicworld
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+
+	"marmalade/classicworld/nbt"
+	"marmalade/helpers"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Read reads a ClassicWorld compound, decompressing it first if it starts with the gzip magic bytes
+// Also returns the order of its keys, so that Decode can keep them in that order, and whether it was compressed
+func Read(r io.Reader) (nbt.Compound, *nbt.Order, bool, error) {
+	bufR := bufio.NewReader(r)
+	magic, _ := bufR.Peek(len(gzipMagic)) // a short file fails in nbt.Read instead
+	compressed := bytes.Equal(magic, gzipMagic)
+	if compressed {
+		gzipR, gzipRErr := gzip.NewReader(bufR)
+		if gzipRErr != nil {
+			return nil, nil, true, gzipRErr
+		}
+		defer func() { _ = gzipR.Close() }()
+		bufR = bufio.NewReader(gzipR)
+	}
+	c, order, _ /* name */, err := nbt.ReadOrdered(bufR)
+	return c, order, compressed, err
+}
+
+// Load reads the ClassicWorld file at path, see Read
+func Load(path string) (nbt.Compound, *nbt.Order, bool, error) {
+	file, fileErr := os.Open(path)
+	if fileErr != nil {
+		return nil, nil, false, fileErr
+	}
+	defer func() { _ = file.Close() }()
+	return Read(file)
+}
+
+// Write writes the NBT produced by actions, gzip compressed if compress is set
+func Write(w io.Writer, compress bool, actions ...helpers.Action) error {
+	if !compress {
+		bufW := bufio.NewWriter(w)
+		if err := nbt.DoWrite(bufW, actions...); err != nil {
+			return err
+		}
+		return bufW.Flush()
+	}
+
+	// file <- buf0 <- gzip <- bufW, which must be flushed and closed in the reverse order
+	buf0 := bufio.NewWriter(w)
+	gzipW := gzip.NewWriter(buf0)
+	bufW := bufio.NewWriter(gzipW)
+	if err := nbt.DoWrite(bufW, actions...); err != nil {
+		return err
+	}
+	if err := bufW.Flush(); err != nil {
+		return err
+	}
+	if err := gzipW.Close(); err != nil {
+		return err
+	}
+	return buf0.Flush()
+}
+
+// Save writes to scratchPath and then swaps it in for path, so that a failed save never leaves a broken world behind
+func Save(path, scratchPath, tempPath string, compress bool, actions ...helpers.Action) error {
+	// Write-only, because we're not going to read or append anything
+	// Create, because we want to create a new file if it didn't previously exist
+	// Truncate, because a previous failed save may have left a longer file behind
+	scratchFile, scratchFileErr := os.OpenFile(scratchPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if scratchFileErr != nil {
+		return scratchFileErr
+	}
+	defer func() { _ = scratchFile.Close() }()
+
+	if err := Write(scratchFile, compress, actions...); err != nil {
+		return err
+	}
+	// Close the file before renaming it
+	if err := scratchFile.Close(); err != nil {
+		return err
+	}
+
+	// First rename path to tempPath instead of deletion in case renaming scratchPath to path fails
+	if err := os.Rename(path, tempPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(scratchPath, path); err != nil {
+		return err
+	}
+
+	// Now we can safely delete the old world
+	if err := os.Remove(tempPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package classicworld
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"marmalade/classicworld/nbt"
+)
+
+const testWorld = "nbt/tests/nbttest.cw"
+
+func TestLoadCompressed(t *testing.T) {
+	c, _, compressed, err := Load(testWorld)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !compressed {
+		t.Fatal("gzip compression not detected")
+	}
+	x, y, z := int(c["X"].(uint16)), int(c["Y"].(uint16)), int(c["Z"].(uint16))
+	if len(c["BlockArray"].([]byte)) != x*y*z {
+		t.Fatalf("block array has %v blocks, expected %v", len(c["BlockArray"].([]byte)), x*y*z)
+	}
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	original, _, _, err := Load(testWorld)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spawn := original["Spawn"].(nbt.Compound)
+
+	for _, compress := range []bool{true, false} {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "world.cw")
+		if err := Save(path, path+"2", path+"_TMP", compress,
+			nbt.WriteCompound("ClassicWorld"),
+			nbt.WriteShort("X", original["X"].(uint16)),
+			nbt.WriteShort("Y", original["Y"].(uint16)),
+			nbt.WriteShort("Z", original["Z"].(uint16)),
+			nbt.WriteCompound("Spawn"),
+			nbt.WriteShort("X", spawn["X"].(uint16)),
+			nbt.WriteShort("Y", spawn["Y"].(uint16)),
+			nbt.WriteShort("Z", spawn["Z"].(uint16)),
+			nbt.WriteByte("H", spawn["H"].(uint8)),
+			nbt.WriteByte("P", spawn["P"].(uint8)),
+			nbt.WriteEnd(), // Spawn
+			nbt.WriteByteArray("BlockArray", original["BlockArray"].([]byte)),
+			nbt.WriteEnd(), // ClassicWorld
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, _, compressed, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed != compress {
+			t.Fatalf("saved with compression %v, but loaded with %v", compress, compressed)
+		}
+		for _, k := range []string{"X", "Y", "Z"} {
+			if loaded[k] != original[k] {
+				t.Fatalf("%v changed from %v to %v", k, original[k], loaded[k])
+			}
+		}
+		if !bytes.Equal(loaded["BlockArray"].([]byte), original["BlockArray"].([]byte)) {
+			t.Fatal("block array changed")
+		}
+	}
+}
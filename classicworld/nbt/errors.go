@@ -7,10 +7,11 @@ import (
 )
 
 var (
-	WrongTypeIDError    = errors.New("marmalade_nbt: wrong type id")
-	InvalidTypeIDError  = errors.New("marmalade_nbt: invalid type id")
-	InvalidLengthError  = errors.New("marmalade_nbt: invalid length")
-	NotImplementedError = errors.New("marmalade_nbt: not implemented")
+	WrongTypeIDError     = errors.New("marmalade_nbt: wrong type id")
+	InvalidTypeIDError   = errors.New("marmalade_nbt: invalid type id")
+	InvalidLengthError   = errors.New("marmalade_nbt: invalid length")
+	NotImplementedError  = errors.New("marmalade_nbt: not implemented")
+	UnsupportedTypeError = errors.New("marmalade_nbt: unsupported value type")
 )
 
 func newWrongTypeIDError(expected, got byte) error {
@@ -36,3 +37,7 @@ func newInvalidTypeIDError(got byte) error {
 func newInvalidLengthError(l interface{}) error {
 	return fmt.Errorf("%w, got %v", InvalidLengthError, l)
 }
+
+func newUnsupportedTypeError(v interface{}) error {
+	return fmt.Errorf("%w, got %T", UnsupportedTypeError, v)
+}
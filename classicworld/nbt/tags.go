@@ -3,6 +3,7 @@ package nbt
 import (
 	"bufio"
 	"encoding/binary"
+	"io"
 	"math"
 	"unsafe"
 
@@ -11,143 +12,221 @@ import (
 )
 
 const (
-	tagEnd byte = iota
-	tagByte
-	tagShort
-	tagInt
-	tagLong
-	tagFloat
-	tagDouble
-	tagByteArray
-	tagString
-	tagList
-	tagCompound
-	tagIntArray
-	tagLongArray
+	TagEnd byte = iota
+	TagByte
+	TagShort
+	TagInt
+	TagLong
+	TagFloat
+	TagDouble
+	TagByteArray
+	TagString
+	TagList
+	TagCompound
+	TagIntArray
+	TagLongArray
 )
 
 func Read(reader *bufio.Reader) (Compound, string, error) {
-	if err := assertTypeID(reader, tagCompound); err != nil {
-		return nil, "", err
+	c, _, name, err := ReadOrdered(reader)
+	return c, name, err
+}
+
+// ReadOrdered is Read, but also returns the order the keys of the compounds were read in, see WriteOrdered
+func ReadOrdered(reader *bufio.Reader) (Compound, *Order, string, error) {
+	if err := assertTypeID(reader, TagCompound); err != nil {
+		return nil, nil, "", err
 	}
 	name, nameErr := readName(reader)
 	if nameErr != nil {
-		return nil, name, nameErr
+		return nil, nil, name, nameErr
 	}
-	c := Compound{}
-	return c, name, readCompound(reader, c)
+	c, order := Compound{}, new(Order)
+	return c, order, name, readCompound(reader, c, order)
 }
 
 // readCompound reads the payload of a compound
 // Note: This interprets the data values as unsigned
 // If you want signed, just cast them to their signed equivalents
-func readCompound(reader *bufio.Reader, out Compound) error {
+func readCompound(reader *bufio.Reader, out Compound, order *Order) error {
 	for {
 		b, bErr := reader.ReadByte()
 		if bErr != nil {
 			return bErr
 		}
-		if b == tagEnd { // `tagEnd`s don't have names
+		if b == TagEnd { // `TagEnd`s don't have names
 			return nil
 		}
 		name, nameErr := readName(reader)
 		if nameErr != nil {
 			return nameErr
 		}
-		switch b {
-		case tagByte:
-			read, err := reader.ReadByte()
-			if err != nil {
-				return err
-			}
-			out[name] = read
-		case tagShort:
-			bs := [unsafe.Sizeof(uint16(0))]byte{}
-			if _, err := reader.Read(bs[:]); err != nil {
-				return err
-			}
-			out[name] = binary.BigEndian.Uint16(bs[:])
-		case tagInt:
-			bs := [unsafe.Sizeof(uint32(0))]byte{}
-			if _, err := reader.Read(bs[:]); err != nil {
-				return err
-			}
-			out[name] = binary.BigEndian.Uint32(bs[:])
-		case tagLong:
-			bs := [unsafe.Sizeof(uint64(0))]byte{}
-			if _, err := reader.Read(bs[:]); err != nil {
-				return err
-			}
-			out[name] = binary.BigEndian.Uint64(bs[:])
-		case tagFloat:
-			bs := [unsafe.Sizeof(float32(0))]byte{}
-			if _, err := reader.Read(bs[:]); err != nil {
-				return err
-			}
-			out[name] = math.Float32frombits(binary.BigEndian.Uint32(bs[:]))
-		case tagDouble:
-			bs := [unsafe.Sizeof(float64(0))]byte{}
-			if _, err := reader.Read(bs[:]); err != nil {
-				return err
-			}
-			out[name] = math.Float64frombits(binary.BigEndian.Uint64(bs[:]))
-		case tagByteArray:
-			prefixBS := [unsafe.Sizeof(int32(0))]byte{}
-			if _, err := reader.Read(prefixBS[:]); err != nil {
-				return err
-			}
-			length := int32(binary.BigEndian.Uint32(prefixBS[:]))
-			if length < 0 { // cannot have a byte array of negative length
-				return newInvalidLengthError(length)
-			}
-			// note: consider expending a bytes.Buffer here instead of allocating a new slice
-			read, readErr := packets.ReadN(reader, int(length)) // use make instead of a bytes.Buffer as the data will be used after the function is done
-			if readErr != nil {
-				return readErr
+		nested := new(Order)
+		v, err := readPayload(reader, b, nested)
+		if err != nil {
+			return err
+		}
+		out[name] = v
+		order.Keys = append(order.Keys, name)
+		if nested.Keys != nil || nested.Elements != nil {
+			if order.Nested == nil {
+				order.Nested = map[string]*Order{}
 			}
-			out[name] = read
-		case tagString:
-			prefixBS := [unsafe.Sizeof(uint16(0))]byte{}
-			if _, err := reader.Read(prefixBS[:]); err != nil {
-				return err
+			order.Nested[name] = nested
+		}
+	}
+}
+
+// readPayload reads the payload of a tag with the given type id, see Value for the types it returns
+// The order of compounds read is put into order
+func readPayload(reader *bufio.Reader, typeID byte, order *Order) (Value, error) {
+	switch typeID {
+	case TagByte:
+		return reader.ReadByte()
+	case TagShort:
+		bs := [unsafe.Sizeof(uint16(0))]byte{}
+		if _, err := io.ReadFull(reader, bs[:]); err != nil {
+			return nil, err
+		}
+		return binary.BigEndian.Uint16(bs[:]), nil
+	case TagInt:
+		return readUint32(reader)
+	case TagLong:
+		return readUint64(reader)
+	case TagFloat:
+		u, err := readUint32(reader)
+		return math.Float32frombits(u), err
+	case TagDouble:
+		u, err := readUint64(reader)
+		return math.Float64frombits(u), err
+	case TagByteArray:
+		length, err := readLength(reader)
+		if err != nil {
+			return nil, err
+		}
+		// note: consider expending a bytes.Buffer here instead of allocating a new slice
+		return packets.ReadN(reader, length) // use make instead of a bytes.Buffer as the data will be used after the function is done
+	case TagString:
+		prefixBS := [unsafe.Sizeof(uint16(0))]byte{}
+		if _, err := io.ReadFull(reader, prefixBS[:]); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(prefixBS[:]))
+		buf := helpers.GetBuffer()
+		defer helpers.PutBuffer(buf)
+		buf.Reset()
+		buf.Write(helpers.GetZeroes(length)) // grow doesn't change the length of buf.Bytes()
+		if _, err := io.ReadFull(reader, buf.Bytes()); err != nil {
+			return nil, err
+		}
+		return buf.String(), nil // this allocates a new string
+	case TagList:
+		elemType, elemTypeErr := reader.ReadByte()
+		if elemTypeErr != nil {
+			return nil, elemTypeErr
+		}
+		length, err := readLength(reader)
+		if err != nil {
+			return nil, err
+		}
+		if elemType == TagEnd && length > 0 { // only empty lists may have no element type
+			return nil, newInvalidTypeIDError(elemType)
+		}
+		l := List{Type: elemType, Values: make([]Value, 0, minInt(length, maxPrealloc))}
+		for i := 0; i < length; i++ {
+			elem := new(Order)
+			v, err := readPayload(reader, elemType, elem)
+			if err != nil {
+				return nil, err
 			}
-			length := int32(binary.BigEndian.Uint16(prefixBS[:]))
-			if length < 0 {
-				return newInvalidLengthError(length)
+			l.Values = append(l.Values, v)
+			if elemType == TagCompound || elemType == TagList {
+				order.Elements = append(order.Elements, elem)
 			}
-			if err := func() error { // use a closure here so that the defer is run once we no longer need the buffer
-				buf := helpers.GetBuffer()
-				defer helpers.PutBuffer(buf)
-				buf.Reset()
-				buf.Write(helpers.GetZeroes(int(length))) // grow doesn't change the length of buf.Bytes()
-				if _, err := reader.Read(buf.Bytes()); err != nil {
-					return err
-				}
-				out[name] = buf.String() // this allocates a new string
-				return nil
-			}(); err != nil {
-				return err
+		}
+		return l, nil
+	case TagCompound:
+		c := Compound{}
+		if err := readCompound(reader, c, order); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case TagIntArray:
+		length, err := readLength(reader)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]uint32, 0, minInt(length, maxPrealloc))
+		for i := 0; i < length; i++ {
+			v, err := readUint32(reader)
+			if err != nil {
+				return nil, err
 			}
-		case tagList:
-			return NotImplementedError // too problematic to implement, and the classic world format doesn't even use them anyways
-		case tagCompound:
-			c := Compound{}
-			if err := readCompound(reader, c); err != nil {
-				return err
+			out = append(out, v)
+		}
+		return out, nil
+	case TagLongArray:
+		length, err := readLength(reader)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]uint64, 0, minInt(length, maxPrealloc))
+		for i := 0; i < length; i++ {
+			v, err := readUint64(reader)
+			if err != nil {
+				return nil, err
 			}
-			out[name] = c
-		case tagIntArray, tagLongArray:
-			return NotImplementedError // classic world format doesn't use these
-		default:
-			return newInvalidTypeIDError(b)
+			out = append(out, v)
 		}
+		return out, nil
+	default:
+		return nil, newInvalidTypeIDError(typeID)
+	}
+}
+
+// lists and arrays don't preallocate more than this many elements, so that a bogus length can't allocate gigabytes up front
+const maxPrealloc = 4096
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func readUint32(reader *bufio.Reader) (uint32, error) {
+	bs := [unsafe.Sizeof(uint32(0))]byte{}
+	if _, err := io.ReadFull(reader, bs[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(bs[:]), nil
+}
+
+func readUint64(reader *bufio.Reader) (uint64, error) {
+	bs := [unsafe.Sizeof(uint64(0))]byte{}
+	if _, err := io.ReadFull(reader, bs[:]); err != nil {
+		return 0, err
 	}
+	return binary.BigEndian.Uint64(bs[:]), nil
+}
+
+// reads the signed 32 bit length prefix of arrays and lists
+func readLength(reader *bufio.Reader) (int, error) {
+	u, err := readUint32(reader)
+	if err != nil {
+		return 0, err
+	}
+	length := int32(u)
+	if length < 0 { // cannot have an array of negative length
+		return 0, newInvalidLengthError(length)
+	}
+	return int(length), nil
 }
 
 // reads a tag name
 func readName(reader *bufio.Reader) (string, error) {
 	bs := [unsafe.Sizeof(uint16(0))]byte{}
-	if _, err := reader.Read(bs[:]); err != nil {
+	if _, err := io.ReadFull(reader, bs[:]); err != nil {
 		return "", err
 	}
 	length := int32(binary.BigEndian.Uint16(bs[:]))
@@ -160,7 +239,7 @@ func readName(reader *bufio.Reader) (string, error) {
 	buf.Reset()
 	buf.Write(helpers.GetZeroes(int(length))) // grow doesn't change the length of buf.Bytes()
 
-	if _, err := reader.Read(buf.Bytes()); err != nil {
+	if _, err := io.ReadFull(reader, buf.Bytes()); err != nil {
 		return "", err
 	}
 
@@ -179,13 +258,13 @@ func DoWrite(writer *bufio.Writer, actions ...helpers.Action) error {
 
 func WriteEnd() helpers.Action {
 	return func(writer *bufio.Writer) error {
-		return writer.WriteByte(tagEnd)
+		return writer.WriteByte(TagEnd)
 	}
 }
 
 func WriteByte(name string, b byte) helpers.Action {
 	return func(writer *bufio.Writer) error {
-		if err := writer.WriteByte(tagByte); err != nil {
+		if err := writer.WriteByte(TagByte); err != nil {
 			return err
 		}
 		if err := writeName(writer, name); err != nil {
@@ -197,7 +276,7 @@ func WriteByte(name string, b byte) helpers.Action {
 
 func WriteShort(name string, s uint16) helpers.Action {
 	return func(writer *bufio.Writer) error {
-		if err := writer.WriteByte(tagShort); err != nil {
+		if err := writer.WriteByte(TagShort); err != nil {
 			return err
 		}
 		if err := writeName(writer, name); err != nil {
@@ -212,7 +291,7 @@ func WriteShort(name string, s uint16) helpers.Action {
 
 func WriteInt(name string, i uint32) helpers.Action {
 	return func(writer *bufio.Writer) error {
-		if err := writer.WriteByte(tagInt); err != nil {
+		if err := writer.WriteByte(TagInt); err != nil {
 			return err
 		}
 		if err := writeName(writer, name); err != nil {
@@ -227,7 +306,7 @@ func WriteInt(name string, i uint32) helpers.Action {
 
 func WriteLong(name string, l uint64) helpers.Action {
 	return func(writer *bufio.Writer) error {
-		if err := writer.WriteByte(tagLong); err != nil {
+		if err := writer.WriteByte(TagLong); err != nil {
 			return err
 		}
 		if err := writeName(writer, name); err != nil {
@@ -242,7 +321,7 @@ func WriteLong(name string, l uint64) helpers.Action {
 
 func WriteFloat(name string, f float32) helpers.Action {
 	return func(writer *bufio.Writer) error {
-		if err := writer.WriteByte(tagFloat); err != nil {
+		if err := writer.WriteByte(TagFloat); err != nil {
 			return err
 		}
 		if err := writeName(writer, name); err != nil {
@@ -257,7 +336,7 @@ func WriteFloat(name string, f float32) helpers.Action {
 
 func WriteDouble(name string, d float64) helpers.Action {
 	return func(writer *bufio.Writer) error {
-		if err := writer.WriteByte(tagDouble); err != nil {
+		if err := writer.WriteByte(TagDouble); err != nil {
 			return err
 		}
 		if err := writeName(writer, name); err != nil {
@@ -272,7 +351,7 @@ func WriteDouble(name string, d float64) helpers.Action {
 
 func WriteByteArray(name string, b []byte) helpers.Action {
 	return func(writer *bufio.Writer) error {
-		if err := writer.WriteByte(tagByteArray); err != nil {
+		if err := writer.WriteByte(TagByteArray); err != nil {
 			return err
 		}
 		if err := writeName(writer, name); err != nil {
@@ -288,7 +367,7 @@ func WriteByteArray(name string, b []byte) helpers.Action {
 
 func WriteString(name, s string) helpers.Action {
 	return func(writer *bufio.Writer) error {
-		if err := writer.WriteByte(tagString); err != nil {
+		if err := writer.WriteByte(TagString); err != nil {
 			return err
 		}
 		if err := writeName(writer, name); err != nil {
@@ -302,20 +381,153 @@ func WriteString(name, s string) helpers.Action {
 	}
 }
 
-// func WriteList
+func WriteList(name string, l List) helpers.Action {
+	return WriteValue(name, l)
+}
 
 func WriteCompound(name string) helpers.Action {
 	return func(writer *bufio.Writer) error {
-		if err := writer.WriteByte(tagCompound); err != nil {
+		if err := writer.WriteByte(TagCompound); err != nil {
 			return err
 		}
 		return writeName(writer, name)
 	}
 }
 
-// func WriteIntArray
+func WriteIntArray(name string, a []uint32) helpers.Action {
+	return WriteValue(name, a)
+}
+
+func WriteLongArray(name string, a []uint64) helpers.Action {
+	return WriteValue(name, a)
+}
+
+// WriteValue writes a tag of any type, the tag type is picked from the type of v, see Value
+// Compounds are written with their keys sorted, so that equal compounds are always written the same way
+func WriteValue(name string, v Value) helpers.Action {
+	return WriteOrdered(name, v, nil)
+}
+
+// WriteOrdered is WriteValue, but writes the keys of compounds in the order they have in order
+// Keys that order doesn't have come after, sorted
+func WriteOrdered(name string, v Value, order *Order) helpers.Action {
+	return func(writer *bufio.Writer) error {
+		typeID, typeErr := TypeOf(v)
+		if typeErr != nil {
+			return typeErr
+		}
+		if err := writer.WriteByte(typeID); err != nil {
+			return err
+		}
+		if err := writeName(writer, name); err != nil {
+			return err
+		}
+		return writePayload(writer, v, order)
+	}
+}
+
+// TypeOf returns the tag type that v is written as
+func TypeOf(v Value) (byte, error) {
+	switch v.(type) {
+	case uint8, int8:
+		return TagByte, nil
+	case uint16, int16:
+		return TagShort, nil
+	case uint32, int32:
+		return TagInt, nil
+	case uint64, int64:
+		return TagLong, nil
+	case float32:
+		return TagFloat, nil
+	case float64:
+		return TagDouble, nil
+	case []byte:
+		return TagByteArray, nil
+	case string:
+		return TagString, nil
+	case List:
+		return TagList, nil
+	case Compound:
+		return TagCompound, nil
+	case []uint32, []int32:
+		return TagIntArray, nil
+	case []uint64, []int64:
+		return TagLongArray, nil
+	default:
+		return 0, newUnsupportedTypeError(v)
+	}
+}
+
+func writePayload(writer *bufio.Writer, v Value, order *Order) error {
+	switch v := v.(type) {
+	case uint8:
+		return writer.WriteByte(v)
+	case int8:
+		return writer.WriteByte(uint8(v))
+	case uint16, int16, uint32, int32, uint64, int64, float32, float64:
+		return binary.Write(writer, binary.BigEndian, v)
+	case []byte:
+		if err := binary.Write(writer, binary.BigEndian, uint32(len(v))); err != nil {
+			return err
+		}
+		_, err := writer.Write(v)
+		return err
+	case string:
+		if err := binary.Write(writer, binary.BigEndian, uint16(len(v))); err != nil {
+			return err
+		}
+		_, err := writer.WriteString(v)
+		return err
+	case List:
+		if err := writer.WriteByte(v.Type); err != nil {
+			return err
+		}
+		if err := binary.Write(writer, binary.BigEndian, uint32(len(v.Values))); err != nil {
+			return err
+		}
+		for i, e := range v.Values {
+			typeID, typeErr := TypeOf(e)
+			if typeErr != nil {
+				return typeErr
+			}
+			if typeID != v.Type {
+				return newWrongTypeIDError(v.Type, typeID)
+			}
+			if err := writePayload(writer, e, order.element(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Compound:
+		for _, k := range order.keys(v) {
+			if err := WriteOrdered(k, v[k], order.nested(k))(writer); err != nil {
+				return err
+			}
+		}
+		return writer.WriteByte(TagEnd)
+	case []uint32, []int32, []uint64, []int64:
+		if err := binary.Write(writer, binary.BigEndian, uint32(arrayLen(v))); err != nil {
+			return err
+		}
+		return binary.Write(writer, binary.BigEndian, v)
+	default:
+		return newUnsupportedTypeError(v)
+	}
+}
 
-// func WriteLongArray
+func arrayLen(v Value) int {
+	switch v := v.(type) {
+	case []uint32:
+		return len(v)
+	case []int32:
+		return len(v)
+	case []uint64:
+		return len(v)
+	case []int64:
+		return len(v)
+	}
+	return 0
+}
 
 func writeName(writer *bufio.Writer, name string) error {
 	nameBS := [unsafe.Sizeof(uint16(0))]byte{}
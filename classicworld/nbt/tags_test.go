@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -17,7 +18,7 @@ func TestReadCompound(t *testing.T) {
 	source := bufio.NewReader(rand.Reader)
 	for {
 		m := Compound{}
-		if err := readCompound(source, m); err != nil {
+		if err := readCompound(source, m, new(Order)); err != nil {
 			log.Println(err)
 		}
 	}
@@ -49,6 +50,87 @@ func TestReadWorld(t *testing.T) {
 	fmt.Println(name)
 }
 
+func TestWriteValueRoundTrip(t *testing.T) {
+	in := Compound{
+		"Byte":      uint8(1),
+		"Short":     uint16(2),
+		"Int":       uint32(3),
+		"Long":      uint64(4),
+		"Float":     float32(5.5),
+		"Double":    6.25,
+		"ByteArray": []byte{7, 8},
+		"String":    "nine",
+		"List":      List{Type: TagCompound, Values: []Value{Compound{"A": uint8(10)}, Compound{}}},
+		"EmptyList": List{Type: TagEnd, Values: []Value{}},
+		"IntArray":  []uint32{11, 12},
+		"LongArray": []uint64{13, 14},
+		"Nested":    Compound{"Strings": List{Type: TagString, Values: []Value{"a", "b"}}},
+	}
+
+	buf := new(bytes.Buffer)
+	writer := bufio.NewWriter(buf)
+	if err := DoWrite(writer, WriteValue("Root", in)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, name, err := Read(bufio.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "Root" {
+		t.Fatalf("expected name Root, got %v", name)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("expected %v, got %v", in, out)
+	}
+}
+
+func TestWriteOrderedRoundTrip(t *testing.T) {
+	in := Compound{
+		"Zebra": uint8(1),
+		"Apple": Compound{"Z": uint8(2), "A": uint8(3), "M": uint8(4)},
+		"List":  List{Type: TagCompound, Values: []Value{Compound{"B": uint8(5), "A": uint8(6)}}},
+		"New":   uint8(7),
+	}
+	order := &Order{
+		Keys: []string{"Zebra", "Gone", "List", "Apple"},
+		Nested: map[string]*Order{
+			"Apple": {Keys: []string{"Z", "A"}},
+			"List":  {Elements: []*Order{{Keys: []string{"B", "A"}}}},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	writer := bufio.NewWriter(buf)
+	if err := DoWrite(writer, WriteOrdered("Root", in, order)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	out, outOrder, _, err := ReadOrdered(bufio.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("expected %v, got %v", in, out)
+	}
+	// keys missing from the order come after the ordered ones
+	for _, c := range []struct{ got, expected []string }{
+		{outOrder.Keys, []string{"Zebra", "List", "Apple", "New"}},
+		{outOrder.Nested["Apple"].Keys, []string{"Z", "A", "M"}},
+		{outOrder.Nested["List"].Elements[0].Keys, []string{"B", "A"}},
+	} {
+		if !reflect.DeepEqual(c.got, c.expected) {
+			t.Errorf("expected the keys %v, got %v", c.expected, c.got)
+		}
+	}
+}
+
 func BenchmarkWriteName(b *testing.B) {
 	buf := new(bytes.Buffer)
 	writer := bufio.NewWriter(buf)
@@ -1,3 +1,60 @@
 package nbt
 
-type Compound = map[string]interface{}
+import "sort"
+
+// Value is the payload of a tag, which is one of
+// uint8, uint16, uint32, uint64, float32, float64, []byte, string, List, Compound, []uint32 or []uint64
+// WriteValue also accepts the signed equivalents of the integer types
+type Value = interface{}
+
+type Compound = map[string]Value
+
+// List is the payload of a list tag, every value in it has the tag type Type
+type List struct {
+	Type   byte
+	Values []Value
+}
+
+// Order is the order the keys of a compound were read in, along with the order of the compounds nested in it
+// A nil *Order has no order, so everything is sorted
+type Order struct {
+	Keys     []string
+	Nested   map[string]*Order // of the compounds and lists in the compound, by key
+	Elements []*Order          // of the compounds and lists in a list, by index
+}
+
+// keys returns the keys of c, the ones in o first and in its order, and then the rest sorted
+func (o *Order) keys(c Compound) []string {
+	out := make([]string, 0, len(c))
+	done := make(map[string]bool, len(c))
+	if o != nil {
+		for _, k := range o.Keys {
+			if _, found := c[k]; found && !done[k] {
+				out = append(out, k)
+				done[k] = true
+			}
+		}
+	}
+	rest := len(out)
+	for k := range c {
+		if !done[k] {
+			out = append(out, k)
+		}
+	}
+	sort.Strings(out[rest:])
+	return out
+}
+
+func (o *Order) nested(key string) *Order {
+	if o == nil {
+		return nil
+	}
+	return o.Nested[key]
+}
+
+func (o *Order) element(i int) *Order {
+	if o == nil || i >= len(o.Elements) {
+		return nil
+	}
+	return o.Elements[i]
+}
@@ -0,0 +1,274 @@
+package classicworld
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"marmalade/classicworld/nbt"
+	"marmalade/helpers"
+)
+
+// FormatVersion is the version of the ClassicWorld format that is read and written
+const FormatVersion = 1
+
+type (
+	// World is a map in the ClassicWorld format, see https://wiki.vg/ClassicWorld_file_format
+	World struct {
+		Name string
+		UUID []byte // 16 bytes
+
+		X, Y, Z uint16
+
+		CreatedBy    CreatedBy    // optional, left out if empty
+		MapGenerator MapGenerator // optional, left out if empty
+
+		// optional, left out if zero
+		TimeCreated  time.Time
+		LastAccessed time.Time
+		LastModified time.Time
+
+		Spawn      Spawn
+		BlockArray []byte // X*Y*Z blocks, indexed by (y*Z+z)*X+x
+
+		// Metadata holds a compound per software that stores extra data in the world
+		// Compounds of other software are kept as they were loaded, so that saving doesn't lose them
+		Metadata nbt.Compound
+
+		// Extra holds the tags in the root compound that aren't part of the format, such as BlockArray2 of the CPE extension
+		Extra nbt.Compound
+
+		// Order is the order of the keys in the file the world was read from, which Encode keeps, nil for sorted keys
+		Order *nbt.Order
+	}
+
+	CreatedBy struct {
+		Service  string
+		Username string
+	}
+
+	MapGenerator struct {
+		Software         string
+		MapGeneratorName string
+	}
+
+	Spawn struct {
+		X, Y, Z uint16
+		H, P    uint8 // heading (yaw) and pitch
+	}
+)
+
+var (
+	InvalidWorldError       = errors.New("classicworld: invalid world")
+	UnsupportedVersionError = errors.New("classicworld: unsupported format version")
+)
+
+// New creates an empty world with a random UUID and TimeCreated set to now
+func New(name string, x, y, z uint16) *World {
+	uuid := make([]byte, 16)
+	_, _ = rand.Read(uuid)
+	uuid[6] = uuid[6]&0x0f | 0x40 // version 4
+	uuid[8] = uuid[8]&0x3f | 0x80 // variant 1
+	return &World{
+		Name:        name,
+		UUID:        uuid,
+		X:           x,
+		Y:           y,
+		Z:           z,
+		TimeCreated: time.Now(),
+		Spawn:       Spawn{X: x / 2, Y: y, Z: z / 2},
+		BlockArray:  make([]byte, int(x)*int(y)*int(z)),
+		Metadata:    nbt.Compound{},
+		Extra:       nbt.Compound{},
+	}
+}
+
+// known tags of the root compound, everything else goes into Extra
+var knownTags = map[string]bool{
+	"FormatVersion": true, "Name": true, "UUID": true, "X": true, "Y": true, "Z": true,
+	"CreatedBy": true, "MapGenerator": true, "TimeCreated": true, "LastAccessed": true, "LastModified": true,
+	"Spawn": true, "BlockArray": true, "Metadata": true,
+}
+
+// Decode reads a World from the root compound of a ClassicWorld file
+// Only X, Y, Z, Spawn and BlockArray are required
+// A missing FormatVersion is taken as version 1, since older versions of marmalade didn't write it
+// order is the order of the keys as returned by Read, or nil
+func Decode(c nbt.Compound, order *nbt.Order) (*World, error) {
+	d := decoder{c: c}
+	w := &World{Metadata: nbt.Compound{}, Extra: nbt.Compound{}, Order: order}
+
+	if _, found := c["FormatVersion"]; found {
+		if version := d.byte("FormatVersion"); d.err == nil && version != FormatVersion {
+			return nil, fmt.Errorf("%w, got %v", UnsupportedVersionError, version)
+		}
+	}
+	w.X, w.Y, w.Z = d.short("X"), d.short("Y"), d.short("Z")
+	w.BlockArray = d.byteArray("BlockArray")
+
+	spawn := decoder{c: d.compound("Spawn")}
+	w.Spawn = Spawn{X: spawn.short("X"), Y: spawn.short("Y"), Z: spawn.short("Z"), H: spawn.byte("H"), P: spawn.byte("P")}
+	if d.err == nil {
+		d.err = spawn.err
+	}
+
+	// optional tags
+	w.Name = d.optionalString(c, "Name")
+	if uuid, ok := c["UUID"].([]byte); ok {
+		w.UUID = uuid
+	}
+	if createdBy, ok := c["CreatedBy"].(nbt.Compound); ok {
+		w.CreatedBy = CreatedBy{Service: d.optionalString(createdBy, "Service"), Username: d.optionalString(createdBy, "Username")}
+	}
+	if mapGenerator, ok := c["MapGenerator"].(nbt.Compound); ok {
+		w.MapGenerator = MapGenerator{Software: d.optionalString(mapGenerator, "Software"), MapGeneratorName: d.optionalString(mapGenerator, "MapGeneratorName")}
+	}
+	w.TimeCreated = d.optionalTime("TimeCreated")
+	w.LastAccessed = d.optionalTime("LastAccessed")
+	w.LastModified = d.optionalTime("LastModified")
+	if metadata, ok := c["Metadata"].(nbt.Compound); ok {
+		w.Metadata = metadata
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(w.BlockArray) != int(w.X)*int(w.Y)*int(w.Z) {
+		return nil, fmt.Errorf("%w, BlockArray has %v blocks, but the world is %vx%vx%v", InvalidWorldError, len(w.BlockArray), w.X, w.Y, w.Z)
+	}
+
+	for k, v := range c {
+		if !knownTags[k] {
+			w.Extra[k] = v
+		}
+	}
+	return w, nil
+}
+
+// Compound returns the root compound of the world, as it is written to a file
+func (w *World) Compound() nbt.Compound {
+	c := nbt.Compound{}
+	for k, v := range w.Extra {
+		c[k] = v
+	}
+
+	c["FormatVersion"] = uint8(FormatVersion)
+	c["Name"] = w.Name
+	if w.UUID != nil {
+		c["UUID"] = w.UUID
+	}
+	c["X"], c["Y"], c["Z"] = w.X, w.Y, w.Z
+	if w.CreatedBy != (CreatedBy{}) {
+		c["CreatedBy"] = nbt.Compound{"Service": w.CreatedBy.Service, "Username": w.CreatedBy.Username}
+	}
+	if w.MapGenerator != (MapGenerator{}) {
+		c["MapGenerator"] = nbt.Compound{"Software": w.MapGenerator.Software, "MapGeneratorName": w.MapGenerator.MapGeneratorName}
+	}
+	putTime(c, "TimeCreated", w.TimeCreated)
+	putTime(c, "LastAccessed", w.LastAccessed)
+	putTime(c, "LastModified", w.LastModified)
+	c["Spawn"] = nbt.Compound{"X": w.Spawn.X, "Y": w.Spawn.Y, "Z": w.Spawn.Z, "H": w.Spawn.H, "P": w.Spawn.P}
+	c["BlockArray"] = w.BlockArray
+	if w.Metadata != nil {
+		c["Metadata"] = w.Metadata
+	} else {
+		c["Metadata"] = nbt.Compound{}
+	}
+	return c
+}
+
+// Encode returns an action that writes the world as the root compound of a file, to be passed into Write or Save
+func (w *World) Encode() helpers.Action {
+	return nbt.WriteOrdered("ClassicWorld", w.Compound(), w.Order)
+}
+
+// timestamps are stored as unix seconds
+func putTime(c nbt.Compound, name string, t time.Time) {
+	if !t.IsZero() {
+		c[name] = uint64(t.Unix())
+	}
+}
+
+// decoder reads required tags of a compound, remembering the first missing or mistyped one
+type decoder struct {
+	c   nbt.Compound
+	err error
+}
+
+func (d *decoder) get(name string) nbt.Value {
+	v, ok := d.c[name]
+	if !ok && d.err == nil {
+		d.err = fmt.Errorf("%w, missing %v", InvalidWorldError, name)
+	}
+	return v
+}
+
+func (d *decoder) wrongType(name string, v nbt.Value) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w, %v has the wrong type %T", InvalidWorldError, name, v)
+	}
+}
+
+func (d *decoder) byte(name string) uint8 {
+	v := d.get(name)
+	b, ok := v.(uint8)
+	if !ok {
+		d.wrongType(name, v)
+	}
+	return b
+}
+
+func (d *decoder) short(name string) uint16 {
+	v := d.get(name)
+	s, ok := v.(uint16)
+	if !ok {
+		d.wrongType(name, v)
+	}
+	return s
+}
+
+func (d *decoder) byteArray(name string) []byte {
+	v := d.get(name)
+	b, ok := v.([]byte)
+	if !ok {
+		d.wrongType(name, v)
+	}
+	return b
+}
+
+func (d *decoder) compound(name string) nbt.Compound {
+	v := d.get(name)
+	c, ok := v.(nbt.Compound)
+	if !ok {
+		d.wrongType(name, v)
+	}
+	return c
+}
+
+// returns the string, or "" if it is missing
+func (d *decoder) optionalString(c nbt.Compound, name string) string {
+	v, ok := c[name]
+	if !ok {
+		return ""
+	}
+	s, ok := v.(string)
+	if !ok {
+		d.wrongType(name, v)
+	}
+	return s
+}
+
+// returns the timestamp, or the zero time if it is missing
+func (d *decoder) optionalTime(name string) time.Time {
+	v, ok := d.c[name]
+	if !ok {
+		return time.Time{}
+	}
+	l, ok := v.(uint64)
+	if !ok {
+		d.wrongType(name, v)
+		return time.Time{}
+	}
+	return time.Unix(int64(l), 0)
+}
@@ -0,0 +1,107 @@
+package classicworld
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"marmalade/classicworld/nbt"
+)
+
+func TestWorldRoundTrip(t *testing.T) {
+	c, order, _, err := Load(testWorld) // made by ClassiCube, with its CPE metadata
+	if err != nil {
+		t.Fatal(err)
+	}
+	// data of some other software, which has to survive untouched
+	c["Metadata"].(nbt.Compound)["OtherSoftware"] = nbt.Compound{
+		"Zones":   nbt.List{Type: nbt.TagCompound, Values: []nbt.Value{nbt.Compound{"Name": "spawn", "Min": []uint32{1, 2, 3}}}},
+		"Seed":    uint64(1234),
+		"Weights": nbt.List{Type: nbt.TagFloat, Values: []nbt.Value{float32(0.5), float32(1)}},
+	}
+	metadataOrder := order.Nested["Metadata"]
+	metadataOrder.Keys = append(metadataOrder.Keys, "OtherSoftware")
+	metadataOrder.Nested["OtherSoftware"] = &nbt.Order{Keys: []string{"Zones", "Weights", "Seed"}}
+	c["BlockArray2"] = make([]byte, len(c["BlockArray"].([]byte)))
+
+	w, err := Decode(c, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Name = "test"
+	w.LastModified = time.Unix(1600000000, 0)
+
+	buf := new(bytes.Buffer)
+	if err := Write(buf, true, w.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	reread, rereadOrder, _, err := Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(w.Compound(), reread) {
+		t.Fatalf("expected %v, got %v", w.Compound(), reread)
+	}
+
+	// the other software's compounds keep their order, ClassiCube's CPE compound isn't sorted either
+	for _, key := range []string{"CPE", "OtherSoftware"} {
+		expected, got := metadataOrder.Nested[key].Keys, rereadOrder.Nested["Metadata"].Nested[key].Keys
+		if !reflect.DeepEqual(expected, got) {
+			t.Fatalf("the keys of %v were reordered from %v to %v", key, expected, got)
+		}
+	}
+
+	w2, err := Decode(reread, rereadOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c["Metadata"], w2.Metadata) {
+		t.Fatalf("metadata changed from %v to %v", c["Metadata"], w2.Metadata)
+	}
+	if !reflect.DeepEqual(c["UUID"], w2.UUID) {
+		t.Fatal("UUID changed")
+	}
+	if _, ok := w2.Extra["BlockArray2"]; !ok {
+		t.Fatal("BlockArray2 was lost")
+	}
+	if w2.Name != "test" || !w2.LastModified.Equal(w.LastModified) || w2.Spawn != w.Spawn {
+		t.Fatalf("expected %+v, got %+v", w, w2)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	w := New("test", 4, 4, 4)
+	c := w.Compound()
+	c["BlockArray"] = []byte{1, 2, 3}
+	if _, err := Decode(c, nil); err == nil {
+		t.Fatal("expected an error for a short BlockArray")
+	}
+	c = w.Compound()
+	delete(c, "Spawn")
+	if _, err := Decode(c, nil); err == nil {
+		t.Fatal("expected an error for a missing Spawn")
+	}
+	c = w.Compound()
+	c["FormatVersion"] = uint8(2)
+	if _, err := Decode(c, nil); err == nil {
+		t.Fatal("expected an error for an unsupported version")
+	}
+}
+
+func TestDecodeWithoutVersion(t *testing.T) {
+	// written the way older versions of marmalade saved worlds
+	c := nbt.Compound{
+		"X": uint16(4), "Y": uint16(2), "Z": uint16(4),
+		"Spawn":      nbt.Compound{"X": uint16(80), "Y": uint16(115), "Z": uint16(48), "H": uint8(64), "P": uint8(0)},
+		"BlockArray": make([]byte, 4*2*4),
+		"Metadata":   nbt.Compound{"Made_With": "marmalade"},
+	}
+	w, err := Decode(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Spawn != (Spawn{X: 80, Y: 115, Z: 48, H: 64}) || w.Metadata["Made_With"] != "marmalade" {
+		t.Fatalf("decoded wrong: %+v", w)
+	}
+}
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"marmalade/accounts"
+	"marmalade/config"
+	"marmalade/world"
+)
+
+const minPasswordLength = 6
+
+// commands that can be run before logging in, regardless of rank
+var loginCommands = map[string]bool{
+	"login":    true,
+	"register": true,
+}
+
+func register(player *world.Player, args []string) {
+	if player.IsAuthenticated() && !config.Accounts {
+		_ = player.Writer.SendMessageStr("[System] Accounts are not enabled on this server.")
+		return
+	}
+	if len(args) != 1 {
+		_ = player.Writer.SendMessageStr("[System] Usage: register <password>")
+		return
+	}
+	if len(args[0]) < minPasswordLength {
+		_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Your password must be at least %v characters long.", minPasswordLength))
+		return
+	}
+
+	if err := accounts.Register(player.Username, args[0]); err != nil {
+		if errors.Is(err, accounts.AlreadyRegisteredError) {
+			_ = player.Writer.SendMessageStr("[System] You are already registered, use /login <password>")
+		} else {
+			log.Printf("[ERROR] Failed to register %v: %v", player.Username, err)
+			_ = player.Writer.SendMessageStr("[System] Failed to register, please try again later.")
+		}
+		return
+	}
+	player.SetAuthenticated()
+	_ = player.Writer.SendMessageStr("[System] Registered and logged in.")
+}
+
+func login(player *world.Player, args []string) {
+	if player.IsAuthenticated() {
+		_ = player.Writer.SendMessageStr("[System] You are already logged in.")
+		return
+	}
+	if len(args) != 1 {
+		_ = player.Writer.SendMessageStr("[System] Usage: login <password>")
+		return
+	}
+
+	ok, err := accounts.Login(player.Username, args[0])
+	if errors.Is(err, accounts.NotRegisteredError) {
+		_ = player.Writer.SendMessageStr("[System] You are not registered, use /register <password>")
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to check the password of %v: %v", player.Username, err)
+		_ = player.Writer.SendMessageStr("[System] Failed to log in, please try again later.")
+		return
+	}
+	if !ok {
+		world.PlayersMu.Lock()
+		player.LoginAttempts++
+		attempts := player.LoginAttempts
+		world.PlayersMu.Unlock()
+		if attempts >= config.MaxLoginAttempts {
+			player.Kick(world.ReasonLoginFailed)
+			return
+		}
+		_ = player.Writer.SendMessageStr("[System] Wrong password.")
+		return
+	}
+	player.SetAuthenticated()
+	_ = player.Writer.SendMessageStr("[System] Logged in.")
+}
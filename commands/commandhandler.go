@@ -5,13 +5,34 @@ import (
 	"strconv"
 	"strings"
 
+	"marmalade/packets"
 	"marmalade/world"
 )
 
 var commandMap = map[string]func(*world.Player, []string){
-	"ping": ping,
-	"tp":   teleport,
-	"fill": fill,
+	"ping":      ping,
+	"tp":        teleport,
+	"fill":      fill,
+	"kick":      kick,
+	"rank":      rank,
+	"ban":       ban,
+	"banip":     banIP,
+	"unban":     unban,
+	"tempban":   tempBan,
+	"whitelist": whitelist,
+	"register":  register,
+	"login":     login,
+	"goto":      gotoWorld,
+	"worlds":    listWorlds,
+	"main":      mainWorld,
+	"newworld":  newWorld,
+	"import":    importWorld,
+	"physics":   physics,
+	"explode":   explode,
+	"undo":      undo,
+	"rollback":  rollback,
+	"about":     about,
+	"zone":      zone,
 }
 
 func HandleCommand(player *world.Player, command string) {
@@ -21,6 +42,18 @@ func HandleCommand(player *world.Player, command string) {
 		_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Unknown command `%v`", split[0]))
 		return
 	}
+	if loginCommands[split[0]] {
+		fun(player, split[1:])
+		return
+	}
+	if !player.IsAuthenticated() {
+		_ = player.Writer.SendMessageStr("[System] You need to log in first, use /login <password>")
+		return
+	}
+	if !player.Can("command." + split[0]) {
+		_ = player.Writer.SendMessageStr("[System] You do not have the permissions to run this command.")
+		return
+	}
 	fun(player, split[1:])
 }
 
@@ -29,10 +62,6 @@ func ping(player *world.Player, _ []string) {
 }
 
 func teleport(player *world.Player, args []string) {
-	// if !player.OP {
-	// 	_ = player.Writer.SendMessageStr("[System] You do not have the permissions to run this command.")
-	// 	return
-	// }
 	switch len(args) {
 	case 1:
 		targetUsername := args[0]
@@ -42,7 +71,7 @@ func teleport(player *world.Player, args []string) {
 
 		// find target player
 		for _, v := range world.Players {
-			if v != nil && strings.EqualFold(targetUsername, v.Username) {
+			if strings.EqualFold(targetUsername, v.Username) {
 				// update player's position
 				// the player will broadcast this new position themselves, so no need to broadcast it for them
 				_ = player.Writer.SendPositionAndOrientation(255, player.X, player.Y, player.Z, player.Yaw, player.Pitch)
@@ -99,18 +128,67 @@ func fill(player *world.Player, args []string) {
 		return
 	}
 
-	if lesserX < 0 || lesserY < 0 || lesserZ < 0 || greaterX >= int(world.XSize) || greaterY >= int(world.YSize) || greaterZ >= int(world.ZSize) {
+	if block < 0 || block > int(packets.MaxBlock(player.Protocol)) {
+		_ = player.Writer.SendMessageStr("[System] Unknown block type.")
+		return
+	}
+	// filling with air deletes the blocks instead
+	mode := byte(1)
+	if block == 0 {
+		mode = 0
+	}
+	if !world.CanPlace(player, mode, byte(block)) || (mode == 1 && world.IsForbidden(byte(block)) && !player.Can("forbidden.bypass")) {
+		_ = player.Writer.SendMessageStr("[System] You are not allowed to place that block.")
+		return
+	}
+
+	if !player.World.InBounds(lesserX, lesserY, lesserZ) || !player.World.InBounds(greaterX, greaterY, greaterZ) {
 		_ = world.SendLargeMessage(player, "[System] Out of bounds coordinate!")
 		return
 	}
 
+	if ok, zone := player.World.CanBuildIn(player, lesserX, lesserY, lesserZ, greaterX, greaterY, greaterZ); !ok {
+		_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] You may not build in zone %v.", zone))
+		return
+	}
+
+	refused := 0
 	for x := lesserX; x <= greaterX; x++ {
 		for y := lesserY; y <= greaterY; y++ {
 			for z := lesserZ; z <= greaterZ; z++ {
-				world.HandleSetBlock(uint16(x), uint16(y), uint16(z), 1, byte(block))
+				if player.World.GetBlock(uint16(x), uint16(y), uint16(z)) == byte(block) {
+					continue
+				}
+				if !world.PlaceBlock(player, uint16(x), uint16(y), uint16(z), mode, byte(block)) {
+					refused++
+				}
 			}
 		}
 	}
 
+	if refused > 0 {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Done, but %v blocks you may not change were skipped.", refused))
+		return
+	}
 	_ = player.Writer.SendMessageStr("Done.")
 }
+
+func kick(player *world.Player, args []string) {
+	if len(args) < 1 {
+		_ = player.Writer.SendMessageStr("[System] Usage: kick <player> [reason]")
+		return
+	}
+
+	target := world.FindConnected(args[0])
+	if target == nil {
+		_ = player.Writer.SendMessageStr("[System] Player not found!")
+		return
+	}
+	if !outranks(player, target.GetRank()) {
+		_ = player.Writer.SendMessageStr("[System] You can only kick players of a lower rank.")
+		return
+	}
+
+	target.Kick(world.Kicked(strings.Join(args[1:], " ")))
+	world.BroadcastMessage(fmt.Sprintf("[System] %v was kicked by %v", target.Username, player.Username))
+}
@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"marmalade/world"
+)
+
+const undoUsage = "[System] Usage: undo [n|seconds], such as /undo 5 for your last 5 changes or /undo 30s for those of the last 30 seconds"
+
+// undo [n|seconds] reverts the player's own last n changes, 1 by default
+// A bare number is a number of changes, so seconds need their unit, and longer durations such as 5m work too
+func undo(player *world.Player, args []string) {
+	if len(args) > 1 {
+		_ = world.SendLargeMessage(player, undoUsage)
+		return
+	}
+	n, since := 1, time.Time{}
+	if len(args) == 1 {
+		if count, err := strconv.Atoi(args[0]); err == nil && count > 0 {
+			n = count
+		} else if d, err := time.ParseDuration(args[0]); err == nil && d > 0 {
+			n, since = -1, time.Now().Add(-d)
+		} else {
+			_ = world.SendLargeMessage(player, undoUsage)
+			return
+		}
+	}
+	w := player.World
+	undone := w.Revert(player.Username, w.History().By(player.Username, since, -1), n)
+	_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Undid %v of your changes.", undone))
+}
+
+func rollback(player *world.Player, args []string) {
+	if len(args) != 2 {
+		_ = player.Writer.SendMessageStr("[System] Usage: rollback <player> <duration>")
+		return
+	}
+	d, err := time.ParseDuration(args[1])
+	if err != nil || d <= 0 {
+		_ = player.Writer.SendMessageStr("[System] Invalid duration, use something like 30s, 5m or 2h.")
+		return
+	}
+	w := player.World
+	undone := w.Revert(player.Username, w.History().By(args[0], time.Now().Add(-d), -1), -1)
+	log.Printf("[INFO] %v rolled back %v changes by %v in %v", player.Username, undone, args[0], w.Name)
+	_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Rolled back %v changes by %v in %v.", undone, args[0], w.Name))
+}
+
+func about(player *world.Player, args []string) {
+	switch len(args) {
+	case 0:
+		player.Inspecting = true
+		_ = player.Writer.SendMessageStr("[System] Click a block to see who changed it.")
+	case 3:
+		coords := make([]int, 3)
+		for i, v := range args {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Invalid coordinate %v.", v))
+				return
+			}
+			coords[i] = n
+		}
+		if !player.World.InBounds(coords[0], coords[1], coords[2]) {
+			_ = world.SendLargeMessage(player, "[System] Out of bounds coordinate!")
+			return
+		}
+		world.SendBlockHistory(player, uint16(coords[0]), uint16(coords[1]), uint16(coords[2]))
+	default:
+		_ = player.Writer.SendMessageStr("[System] Usage: about [<x> <y> <z>]")
+	}
+}
@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"marmalade/auth"
+	"marmalade/moderation"
+	"marmalade/ranks"
+	"marmalade/world"
+)
+
+// whether the player may kick or ban a player of the rank, which has to be lower than their own
+func outranks(player *world.Player, rank *ranks.Rank) bool {
+	return rank.Level < player.GetRank().Level
+}
+
+func ban(player *world.Player, args []string) {
+	if len(args) < 1 {
+		_ = player.Writer.SendMessageStr("[System] Usage: ban <player> [reason]")
+		return
+	}
+	banName(player, args[0], strings.Join(args[1:], " "), 0)
+}
+
+func tempBan(player *world.Player, args []string) {
+	if len(args) < 2 {
+		_ = player.Writer.SendMessageStr("[System] Usage: tempban <player> <duration> [reason]")
+		return
+	}
+	duration, durationErr := parseDuration(args[1])
+	if durationErr != nil || duration <= 0 {
+		_ = player.Writer.SendMessageStr("[System] Invalid duration, try something like 30m, 12h or 7d")
+		return
+	}
+	banName(player, args[0], strings.Join(args[2:], " "), duration)
+}
+
+// a duration of 0 bans permanently
+func banName(player *world.Player, username, reason string, duration time.Duration) {
+	if !auth.ValidUsername(username) {
+		_ = player.Writer.SendMessageStr("[System] Invalid username.")
+		return
+	}
+
+	// the saved rank, so that impostors still waiting to log in don't make the name bannable
+	if !outranks(player, ranks.Of(username)) {
+		_ = player.Writer.SendMessageStr("[System] You can only ban players of a lower rank.")
+		return
+	}
+
+	b := moderation.Ban{Target: username, Reason: reason, By: player.Username, Created: time.Now()}
+	if duration > 0 {
+		b.Expires = b.Created.Add(duration)
+	}
+	if err := moderation.Bans.BanName(b); err != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to save ban: %v", err))
+		return
+	}
+
+	if target := world.FindConnected(username); target != nil {
+		target.Kick(world.Banned(b.Message()))
+	}
+	world.BroadcastMessage(fmt.Sprintf("[System] %v was banned by %v", username, player.Username))
+}
+
+// banip <ip|cidr|player> [duration] [reason], the ban is permanent without a duration
+func banIP(player *world.Player, args []string) {
+	if len(args) < 1 {
+		_ = player.Writer.SendMessageStr("[System] Usage: banip <ip|cidr|player> [duration] [reason]")
+		return
+	}
+
+	target := args[0]
+	if online := world.FindConnected(target); online != nil {
+		target = online.IP().String()
+	}
+	network, networkErr := auth.ParseNetwork(target)
+	if networkErr != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to ban IP: %v", networkErr))
+		return
+	}
+	connected := append(world.OnlinePlayers(), world.QueuedPlayers()...)
+	for _, v := range connected {
+		if auth.InNetworks(v.IP(), []*net.IPNet{network}) && !outranks(player, v.GetRank()) {
+			_ = world.SendLargeMessage(player, fmt.Sprintf("[System] You can't ban %v, %v is on it and not of a lower rank.", network, v.Username))
+			return
+		}
+	}
+
+	reason := args[1:]
+	var duration time.Duration
+	if len(reason) > 0 {
+		if d, err := parseDuration(reason[0]); err == nil && d > 0 {
+			duration, reason = d, reason[1:]
+		}
+	}
+	b := moderation.Ban{Target: target, Reason: strings.Join(reason, " "), By: player.Username, Created: time.Now()}
+	if duration > 0 {
+		b.Expires = b.Created.Add(duration)
+	}
+	if err := moderation.Bans.BanIP(b); err != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to ban IP: %v", err))
+		return
+	}
+
+	kicked := 0
+	for _, v := range connected {
+		if auth.InNetworks(v.IP(), []*net.IPNet{network}) {
+			v.Kick(world.Banned(b.Message()))
+			kicked++
+		}
+	}
+	_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Banned %v, kicked %v players.", network, kicked))
+}
+
+func unban(player *world.Player, args []string) {
+	if len(args) != 1 {
+		_ = player.Writer.SendMessageStr("[System] Usage: unban <player|ip|cidr>")
+		return
+	}
+
+	found, err := moderation.Bans.Unban(args[0])
+	if err != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to save bans: %v", err))
+		return
+	}
+	if !found {
+		_ = player.Writer.SendMessageStr("[System] No ban found.")
+		return
+	}
+	_ = player.Writer.SendMessageStr("Done.")
+}
+
+func whitelist(player *world.Player, args []string) {
+	if len(args) < 1 {
+		_ = player.Writer.SendMessageStr("[System] Usage: whitelist <add|remove|list|on|off> [player]")
+		return
+	}
+
+	var err error
+	switch args[0] {
+	case "add", "remove":
+		if len(args) != 2 || !auth.ValidUsername(args[1]) {
+			_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Usage: whitelist %v <player>", args[0]))
+			return
+		}
+		if args[0] == "add" {
+			err = moderation.Whitelist.Add(args[1])
+		} else {
+			var found bool
+			found, err = moderation.Whitelist.Remove(args[1])
+			if err == nil && !found {
+				_ = player.Writer.SendMessageStr("[System] That player is not on the whitelist.")
+				return
+			}
+		}
+	case "list":
+		state := "off"
+		if moderation.Whitelist.IsEnabled() {
+			state = "on"
+		}
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Whitelist (%v): %v", state, strings.Join(moderation.Whitelist.List(), ", ")))
+		return
+	case "on", "off":
+		err = moderation.Whitelist.SetEnabled(args[0] == "on")
+	default:
+		_ = player.Writer.SendMessageStr("[System] Usage: whitelist <add|remove|list|on|off> [player]")
+		return
+	}
+	if err != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to save whitelist: %v", err))
+		return
+	}
+	_ = player.Writer.SendMessageStr("Done.")
+}
+
+// like time.ParseDuration, but also accepts days (d) and weeks (w) as a single unit, such as 7d
+func parseDuration(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}
+	if len(s) > 1 {
+		if unit, found := units[s[len(s)-1]]; found {
+			n, err := strconv.Atoi(s[:len(s)-1])
+			return time.Duration(n) * unit, err
+		}
+	}
+	return time.ParseDuration(s)
+}
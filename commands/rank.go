@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"marmalade/auth"
+	"marmalade/ranks"
+	"marmalade/world"
+)
+
+func rank(player *world.Player, args []string) {
+	if len(args) < 1 {
+		_ = player.Writer.SendMessageStr("[System] Usage: rank <set|info|list> ...")
+		return
+	}
+	switch args[0] {
+	case "set":
+		rankSet(player, args[1:])
+	case "info":
+		rankInfo(player, args[1:])
+	case "list":
+		var names []string
+		for _, v := range ranks.All() {
+			names = append(names, fmt.Sprintf("%v (%v)", v.Name, v.Level))
+		}
+		_ = world.SendLargeMessage(player, "[System] Ranks: "+strings.Join(names, ", "))
+	default:
+		_ = player.Writer.SendMessageStr("[System] Usage: rank <set|info|list> ...")
+	}
+}
+
+func rankSet(player *world.Player, args []string) {
+	if !player.Can("rank.set") {
+		_ = player.Writer.SendMessageStr("[System] You do not have the permissions to run this command.")
+		return
+	}
+	if len(args) != 2 || !auth.ValidUsername(args[0]) {
+		_ = player.Writer.SendMessageStr("[System] Usage: rank set <player> <rank>")
+		return
+	}
+	newRank := ranks.Get(args[1])
+	if newRank == nil {
+		_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Unknown rank `%v`", args[1]))
+		return
+	}
+
+	// players can only manage players below them, and can't give out ranks above their own
+	own := player.GetRank()
+	target := world.FindPlayer(args[0])
+	current := ranks.Of(args[0])
+	if target != nil {
+		current = target.GetRank()
+	}
+	if newRank.Level > own.Level || (current.Level >= own.Level && !strings.EqualFold(args[0], player.Username)) {
+		_ = player.Writer.SendMessageStr("[System] You can't change the rank of that player to that rank.")
+		return
+	}
+
+	var err error
+	if target != nil {
+		err = world.SetRank(target, newRank)
+	} else {
+		err = ranks.Set(args[0], newRank)
+	}
+	if err != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to set rank: %v", err))
+		return
+	}
+	if target != nil {
+		_ = target.Writer.SendMessageStr(fmt.Sprintf("[System] Your rank is now %v.", newRank.Name))
+	}
+	_ = player.Writer.SendMessageStr("Done.")
+}
+
+func rankInfo(player *world.Player, args []string) {
+	username := player.Username
+	if len(args) > 0 {
+		username = args[0]
+	}
+	r := ranks.Of(username)
+	if target := world.FindPlayer(username); target != nil {
+		r = target.GetRank()
+	}
+
+	info := fmt.Sprintf("[System] %v has rank %v (level %v)", username, r.Name, r.Level)
+	if r.Inherits != "" {
+		info += fmt.Sprintf(", inheriting %v", r.Inherits)
+	}
+	if r.OP {
+		info += ", operator"
+	}
+	_ = world.SendLargeMessage(player, info)
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"marmalade/config"
+	"marmalade/generator"
+	"marmalade/world"
+)
+
+func gotoWorld(player *world.Player, args []string) {
+	if len(args) != 1 {
+		_ = player.Writer.SendMessageStr("[System] Usage: goto <world>")
+		return
+	}
+	w := world.Get(args[0])
+	if w == nil {
+		_ = player.Writer.SendMessageStr("[System] World not found!")
+		return
+	}
+	moveTo(player, w)
+}
+
+func mainWorld(player *world.Player, _ []string) {
+	moveTo(player, world.Main)
+}
+
+func moveTo(player *world.Player, w *world.World) {
+	if player.World == w {
+		_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] You are already in %v.", w.Name))
+		return
+	}
+	if err := world.MovePlayer(player, w); err != nil {
+		log.Printf("[ERROR] Failed to move %v to world %v: %v", player.Username, w.Name, err)
+		player.Kick(world.ReasonMapSendFailed)
+		return
+	}
+	_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Moved to %v.", w.Name))
+}
+
+func listWorlds(player *world.Player, _ []string) {
+	all := world.All()
+	names := make([]string, 0, len(all))
+	for _, v := range all {
+		names = append(names, fmt.Sprintf("%v (%v)", v.Name, v.PlayerCount()))
+	}
+	_ = world.SendLargeMessage(player, "[System] Worlds: "+strings.Join(names, ", "))
+}
+
+func newWorld(player *world.Player, args []string) {
+	if len(args) != 2 && len(args) != 3 && len(args) != 5 && len(args) != 6 {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Usage: newworld <name> <%v> [<x> <y> <z>] [seed]", strings.Join(generator.Names(), "|")))
+		return
+	}
+	name, generatorName := args[0], args[1]
+	size := []int{config.WorldSizeX, config.WorldSizeY, config.WorldSizeZ}
+	seed := ""
+	if len(args) >= 5 {
+		for i, v := range args[2:5] {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < generator.MinSize || n > generator.MaxSize {
+				_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Sizes must be between %v and %v.", generator.MinSize, generator.MaxSize))
+				return
+			}
+			size[i] = n
+		}
+		args = args[3:]
+	}
+	if len(args) == 3 {
+		seed = args[2]
+	}
+
+	_ = player.Writer.SendMessageStr("[System] Generating...")
+	w, err := world.Create(name, generatorName, uint16(size[0]), uint16(size[1]), uint16(size[2]), generator.ParseSeed(seed))
+	if err != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to create world: %v", err))
+		return
+	}
+	_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Created %v, use /goto %v to go there.", w.Name, w.Name))
+}
+
+func importWorld(player *world.Player, args []string) {
+	if len(args) != 1 && len(args) != 2 {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Usage: import <file in %v> [name]", config.ImportDir))
+		return
+	}
+	// only files in the import directory, so that players can't read the rest of the disk
+	file := args[0]
+	if file != filepath.Base(file) || strings.ContainsAny(file, `/\`) || file == ".." {
+		_ = player.Writer.SendMessageStr("[System] Give only the name of a file in the import directory.")
+		return
+	}
+	name := ""
+	if len(args) == 2 {
+		name = args[1]
+	}
+
+	_ = player.Writer.SendMessageStr("[System] Importing...")
+	w, err := world.ImportWorld(filepath.Join(config.ImportDir, file), name)
+	if err != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to import world: %v", err))
+		return
+	}
+	_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Imported %v, use /goto %v to go there.", w.Name, w.Name))
+}
+
+func physics(player *world.Player, args []string) {
+	w := player.World
+	switch len(args) {
+	case 0:
+		_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Physics in %v: %v", w.Name, w.PhysicsMode()))
+	case 1:
+		if err := w.SetPhysicsMode(strings.ToLower(args[0])); err != nil {
+			_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to change physics: %v", err))
+			return
+		}
+		_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Physics in %v set to %v.", w.Name, w.PhysicsMode()))
+	default:
+		_ = player.Writer.SendMessageStr("[System] Usage: physics [off|infinite|finite]")
+	}
+}
+
+func explode(player *world.Player, args []string) {
+	x, y, z := player.BlockPosition()
+	switch len(args) {
+	case 0:
+	case 3:
+		coords := make([]int, 3)
+		for i, v := range args {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Invalid coordinate %v.", v))
+				return
+			}
+			coords[i] = n
+		}
+		x, y, z = coords[0], coords[1], coords[2]
+	default:
+		_ = player.Writer.SendMessageStr("[System] Usage: explode [<x> <y> <z>]")
+		return
+	}
+	if !player.World.InBounds(x, y, z) {
+		_ = world.SendLargeMessage(player, "[System] Out of bounds coordinate!")
+		return
+	}
+	n := player.World.Explode(player.Username, x, y, z)
+	_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Boom! %v blocks destroyed.", n))
+}
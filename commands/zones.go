@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"marmalade/ranks"
+	"marmalade/world"
+)
+
+const zoneUsage = "[System] Usage: zone list | info <name> | create <name> <x1> <y1> <z1> <x2> <y2> <z2> | delete <name> | " +
+	"edit <name> owners|allow|deny add|remove <player> | edit <name> minrank <rank|none>"
+
+func zone(player *world.Player, args []string) {
+	if len(args) == 0 {
+		_ = world.SendLargeMessage(player, zoneUsage)
+		return
+	}
+	switch sub, args := strings.ToLower(args[0]), args[1:]; {
+	case sub == "list" && len(args) == 0:
+		listZones(player)
+	case sub == "info" && len(args) == 1:
+		zoneInfo(player, args[0])
+	case sub == "create" && len(args) == 7:
+		createZone(player, args)
+	case sub == "delete" && len(args) == 1:
+		deleteZone(player, args[0])
+	case sub == "edit" && len(args) >= 3:
+		editZone(player, args)
+	default:
+		_ = world.SendLargeMessage(player, zoneUsage)
+	}
+}
+
+func listZones(player *world.Player) {
+	zones := player.World.Zones()
+	if len(zones) == 0 {
+		_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] There are no zones in %v.", player.World.Name))
+		return
+	}
+	names := make([]string, len(zones))
+	for i, v := range zones {
+		names[i] = v.Name
+	}
+	_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Zones in %v: %v", player.World.Name, strings.Join(names, ", ")))
+}
+
+func zoneInfo(player *world.Player, name string) {
+	z, found := player.World.GetZone(name)
+	if !found {
+		_ = player.Writer.SendMessageStr("[System] Zone not found!")
+		return
+	}
+	minRank := z.MinRank
+	if minRank == "" {
+		minRank = "none"
+	}
+	_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Zone %v: %v %v %v to %v %v %v", z.Name, z.Min[0], z.Min[1], z.Min[2], z.Max[0], z.Max[1], z.Max[2]))
+	_ = world.SendLargeMessage(player, fmt.Sprintf("Owners: %v; allowed: %v; denied: %v; min rank: %v",
+		listOrNone(z.Owners), listOrNone(z.Allow), listOrNone(z.Deny), minRank))
+}
+
+func listOrNone(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
+
+func createZone(player *world.Player, args []string) {
+	if !player.Can("zone.create") {
+		_ = player.Writer.SendMessageStr("[System] You do not have the permissions to create zones.")
+		return
+	}
+	var corners [6]uint16
+	for i, v := range args[1:] {
+		n, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Invalid coordinate %v.", v))
+			return
+		}
+		corners[i] = uint16(n)
+	}
+	z := world.Zone{
+		Name:   args[0],
+		Min:    [3]uint16{corners[0], corners[1], corners[2]},
+		Max:    [3]uint16{corners[3], corners[4], corners[5]},
+		Owners: []string{player.Username},
+	}
+	if err := player.World.AddZone(z); err != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to create zone: %v", err))
+		return
+	}
+	_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Created zone %v.", z.Name))
+}
+
+// whether the player may edit or delete the zone, tells them if not
+func canManageZone(player *world.Player, name string) bool {
+	z, found := player.World.GetZone(name)
+	if !found {
+		_ = player.Writer.SendMessageStr("[System] Zone not found!")
+		return false
+	}
+	if !z.IsOwner(player.Username) && !player.Can("zone.manage") {
+		_ = player.Writer.SendMessageStr("[System] Only owners of the zone may change it.")
+		return false
+	}
+	return true
+}
+
+func deleteZone(player *world.Player, name string) {
+	if !canManageZone(player, name) {
+		return
+	}
+	if err := player.World.RemoveZone(name); err != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to delete zone: %v", err))
+		return
+	}
+	_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Deleted zone %v.", name))
+}
+
+func editZone(player *world.Player, args []string) {
+	name, field := args[0], strings.ToLower(args[1])
+	var edit func(z *world.Zone)
+	switch {
+	case field == "minrank" && len(args) == 3:
+		rank := args[2]
+		if strings.EqualFold(rank, "none") {
+			rank = ""
+		} else if r := ranks.Get(rank); r != nil {
+			rank = r.Name
+		} else {
+			_ = player.Writer.SendMessageStr("[System] Rank not found!")
+			return
+		}
+		edit = func(z *world.Zone) { z.MinRank = rank }
+	case (field == "owners" || field == "allow" || field == "deny") && len(args) == 4:
+		add := strings.EqualFold(args[2], "add")
+		if !add && !strings.EqualFold(args[2], "remove") {
+			_ = world.SendLargeMessage(player, zoneUsage)
+			return
+		}
+		username := args[3]
+		edit = func(z *world.Zone) {
+			list := map[string]*[]string{"owners": &z.Owners, "allow": &z.Allow, "deny": &z.Deny}[field]
+			*list = removeName(*list, username)
+			if add {
+				*list = append(*list, username)
+			}
+		}
+	default:
+		_ = world.SendLargeMessage(player, zoneUsage)
+		return
+	}
+
+	if !canManageZone(player, name) {
+		return
+	}
+	if err := player.World.EditZone(name, edit); err != nil {
+		_ = world.SendLargeMessage(player, fmt.Sprintf("[System] Failed to edit zone: %v", err))
+		return
+	}
+	_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Changed zone %v.", name))
+}
+
+func removeName(names []string, username string) []string {
+	out := names[:0]
+	for _, v := range names {
+		if !strings.EqualFold(v, username) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
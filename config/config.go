@@ -3,20 +3,71 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 var (
-	Address             = get("MM_ADDR", "127.0.0.1:25565")
-	ServerName          = get("MM_SRVNM", "marmalade")
-	ServerMOTD          = get("MM_SRVMOTD", "placeholder MOTD, ask the server owner to set one!")
-	BufferFlushInterval = time.Second / time.Duration(mustAtoi(get("MM_TICKRATE", "20"))) // value to be passed into the AFCBW constructor
-	WorldPath           = get("MM_WPATH", "world.ucw")                                    // uncompressed classic world
-	WorldScratchPath    = get("MM_WSPATH", WorldPath+"2")
-	WorldTempPath       = get("MM_WTMPRNPATH", WorldPath+"_TMP")
-	WorldSaveDelay      = time.Second * time.Duration(mustAtoi(get("MM_WSAVEDELAY", "30")))
-	CommandPrefix       = get("MM_CMDPRFX", "/")
-	WelcomeMessage      = get("MM_WELCOMEMSG",
+	Address              = get("MM_ADDR", "127.0.0.1:25565")
+	ServerName           = get("MM_SRVNM", "marmalade")
+	ServerMOTD           = get("MM_SRVMOTD", "placeholder MOTD, ask the server owner to set one!")
+	BufferFlushInterval  = time.Second / time.Duration(mustAtoi(get("MM_TICKRATE", "20"))) // value to be passed into the AFCBW constructor
+	WorldsDir            = get("MM_WORLDSDIR", "worlds")                                   // every .cw and .ucw classic world in it is loaded, gzip compressed or not
+	MainWorld            = get("MM_MAINWORLD", "main")                                     // name of the world players join in, without the file extension
+	LegacyWorldPath      = get("MM_WPATH", "world.ucw")                                    // only world of older versions, moved into WorldsDir as the main world if that has none
+	WorldGenerator       = get("MM_WGENERATOR", "natural")                                 // generator of the main world if it doesn't exist yet
+	WorldSizeX           = mustAtoi(get("MM_WSIZEX", "256"))
+	WorldSizeY           = mustAtoi(get("MM_WSIZEY", "64"))
+	WorldSizeZ           = mustAtoi(get("MM_WSIZEZ", "256"))
+	WorldSeed            = get("MM_WSEED", "")                                                 // a number or any other text, random if empty
+	WorldFormat          = get("MM_WFORMAT", "auto")                                           // "cw" saves compressed, "ucw" uncompressed, "auto" the same as when loaded
+	ImportDir            = get("MM_IMPORTDIR", "import")                                       // maps of other servers that can be imported with /import
+	Physics              = get("MM_PHYSICS", "infinite")                                       // physics of worlds that have none set: "off", "infinite" or "finite" liquids
+	PhysicsTickInterval  = time.Second / time.Duration(mustAtoi(get("MM_PHYSICSTPS", "10")))   // physics ticks per second
+	PhysicsBudget        = mustAtoi(get("MM_PHYSICSBUDGET", "2000"))                           // block updates per world per tick, the rest wait for the next tick
+	PhysicsMaxQueue      = mustAtoi(get("MM_PHYSICSMAXQUEUE", "100000"))                       // scheduled block updates per world, more are dropped
+	RandomTickInterval   = time.Second / time.Duration(mustAtoi(get("MM_RANDOMTICKTPS", "2"))) // random ticks per second, in which grass spreads and saplings grow
+	RandomTickSpeed      = mustAtoi(get("MM_RANDOMTICKSPEED", "3"))                            // blocks picked per 16x16x16 blocks every random tick, 0 to disable
+	BlastRadius          = mustAtoi(get("MM_BLASTRADIUS", "4"))
+	BlastImmune          = splitList(get("MM_BLASTIMMUNE", "7,8,9,10,11,49"))            // comma separated block ids explosions don't destroy
+	MaxBlastChain        = mustAtoi(get("MM_MAXBLASTCHAIN", "64"))                       // explosions one TNT can set off, including itself
+	TNTFuse              = time.Second * time.Duration(mustAtoi(get("MM_TNTFUSE", "3"))) // delay before lit TNT explodes
+	IgnitePlacedTNT      = mustParseBool(get("MM_IGNITEPLACEDTNT", "false"))             // TNT placed by players is lit, otherwise only lava lights it
+	MaxReach             = mustAtoi(get("MM_MAXREACH", "6"))                             // blocks players can change at most this far from their eyes, 0 for no limit
+	ForbiddenBlocks      = splitList(get("MM_FORBIDDENBLOCKS", "0,7,8,9,10,11"))         // comma separated block ids only ranks with forbidden.bypass may place or delete, 0 forbids placing air
+	WorldSaveDelay       = time.Second * time.Duration(mustAtoi(get("MM_WSAVEDELAY", "30")))
+	CommandPrefix        = get("MM_CMDPRFX", "/")
+	PacketPolicy         = get("MM_PKTPOLICY", "reject")         // "reject" or "skip" disabled and unhandled inbound packets
+	DisabledPackets      = splitList(get("MM_DISABLEDPKTS", "")) // comma separated inbound packet ids, such as 0x0d
+	Operators            = splitList(get("MM_OPS", ""))          // comma separated usernames given the highest rank while they have none saved
+	MaxPlayers           = mustAtoi(get("MM_MAXPLAYERS", "255"))
+	MaxVisiblePlayers    = mustAtoi(get("MM_MAXVISIBLE", "127"))       // players one client can see at once, can't be more than 128
+	ReservedSlots        = mustAtoi(get("MM_RESERVEDSLOTS", "0"))      // slots only usable by ranks with the slot.reserved permission
+	JoinQueue            = mustParseBool(get("MM_JOINQUEUE", "false")) // let players wait for a slot when the server is full
+	MaxQueueLength       = mustAtoi(get("MM_MAXQUEUE", "50"))
+	QueueUpdateInterval  = time.Second * time.Duration(mustAtoi(get("MM_QUEUEUPDATE", "10")))
+	Public               = mustParseBool(get("MM_PUBLIC", "false"))
+	HeartbeatURL         = get("MM_HEARTBEATURL", "") // server list to announce the server to, such as https://www.classicube.net/server/heartbeat/, empty to disable
+	HeartbeatInterval    = time.Second * time.Duration(mustAtoi(get("MM_HEARTBEATDELAY", "45")))
+	HeartbeatMaxBackoff  = time.Second * time.Duration(mustAtoi(get("MM_HEARTBEATMAXBACKOFF", "600")))
+	VerifyNames          = mustParseBool(get("MM_VERIFYNAMES", "false"))            // check usernames against the heartbeat salt
+	VerifyExempt         = splitList(get("MM_VERIFYEXEMPT", "127.0.0.0/8,::1/128")) // comma separated IPs and CIDRs that skip name verification
+	DuplicateLogin       = get("MM_DUPLOGIN", "kick-old")                           // "kick-old" replaces the online session unless it is logged in and the new one isn't, "reject-new" refuses the new one
+	MaxConnsPerIP        = mustAtoi(get("MM_MAXCONNSPERIP", "3"))                   // 0 for no limit
+	JoinsPerIP           = mustAtoi(get("MM_JOINSPERIP", "5"))                      // joins allowed from one IP within JoinWindow, 0 for no limit
+	JoinWindow           = time.Second * time.Duration(mustAtoi(get("MM_JOINWINDOW", "30")))
+	JoinCooldown         = time.Second * time.Duration(mustAtoi(get("MM_JOINCOOLDOWN", "60")))
+	MaxPendingHandshakes = mustAtoi(get("MM_MAXPENDING", "32")) // 0 for no limit
+	HandshakeTimeout     = time.Second * time.Duration(mustAtoi(get("MM_HANDSHAKETIMEOUT", "10")))
+	RanksPath            = get("MM_RANKSPATH", "ranks.json")
+	PlayerRanksPath      = get("MM_PLAYERRANKSPATH", "playerranks.json")
+	Accounts             = mustParseBool(get("MM_ACCOUNTS", "false")) // require /register and /login from players whose name wasn't verified
+	AccountsPath         = get("MM_ACCOUNTSPATH", "accounts.json")
+	LoginTimeout         = time.Second * time.Duration(mustAtoi(get("MM_LOGINTIMEOUT", "60")))
+	MaxLoginAttempts     = mustAtoi(get("MM_MAXLOGINATTEMPTS", "3"))
+	BansPath             = get("MM_BANSPATH", "bans.json")
+	WhitelistPath        = get("MM_WHITELISTPATH", "whitelist.json")
+	WelcomeMessage       = get("MM_WELCOMEMSG",
 		"marmalade is free and open source software licensed under the GNU Affero General Public License. "+
 			"The full source code can be found at https://github.com/360ied/marmalade")
 )
@@ -30,6 +81,25 @@ func get(key, fallback string) string {
 	}
 }
 
+// splits a comma separated list, ignoring surrounding whitespace and empty items
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func mustParseBool(s string) bool {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
 func mustAtoi(s string) int {
 	n, err := strconv.Atoi(s)
 	if err != nil {
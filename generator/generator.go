@@ -0,0 +1,128 @@
+// Package generator creates new maps, see Generate
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/classicworld"
+	"marmalade/classicworld/nbt"
+)
+
+// Generator fills an empty map, using r for anything random so that the same seed always makes the same map
+type Generator func(g *Grid, r *rand.Rand)
+
+var generators = map[string]Generator{
+	"flat":    flat,
+	"empty":   empty,
+	"canvas":  canvas,
+	"natural": natural,
+}
+
+const (
+	MinSize   = 16
+	MaxSize   = 1024
+	MaxVolume = 256 * 256 * 256
+)
+
+var (
+	UnknownGeneratorError = errors.New("unknown generator")
+	InvalidSizeError      = errors.New("invalid map size")
+)
+
+// Names returns the names of the generators, sorted
+func Names() []string {
+	out := make([]string, 0, len(generators))
+	for k := range generators {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Generate creates a map with the named generator
+// Each side must be between MinSize and MaxSize blocks long, and the map can't have more than MaxVolume blocks
+func Generate(generator, name string, x, y, z uint16, seed int64) (*classicworld.World, error) {
+	gen, found := generators[generator]
+	if !found {
+		return nil, fmt.Errorf("%w `%v`", UnknownGeneratorError, generator)
+	}
+	if !validSide(x) || !validSide(y) || !validSide(z) || int(x)*int(y)*int(z) > MaxVolume {
+		return nil, fmt.Errorf("%w %vx%vx%v", InvalidSizeError, x, y, z)
+	}
+
+	w := classicworld.New(name, x, y, z)
+	w.MapGenerator = classicworld.MapGenerator{Software: "marmalade", MapGeneratorName: generator}
+	w.Metadata["marmalade"] = nbt.Compound{"Seed": uint64(seed)}
+
+	g := &Grid{Blocks: w.BlockArray, X: int(x), Y: int(y), Z: int(z)}
+	gen(g, rand.New(rand.NewSource(seed)))
+
+	// spawn on top of the middle of the map
+	sx, sz := g.X/2, g.Z/2
+	sy := g.Y - 1
+	for sy > 0 && g.Get(sx, sy-1, sz) == blocks.Air {
+		sy--
+	}
+	w.Spawn = classicworld.Spawn{X: uint16(sx), Y: uint16(sy), Z: uint16(sz)}
+	return w, nil
+}
+
+func validSide(s uint16) bool {
+	return s >= MinSize && s <= MaxSize
+}
+
+// Grid gives access to the blocks of a map by their coordinates
+type Grid struct {
+	Blocks  []byte // indexed by (y*Z+z)*X+x, like a ClassicWorld BlockArray
+	X, Y, Z int
+}
+
+func (g *Grid) InBounds(x, y, z int) bool {
+	return x >= 0 && y >= 0 && z >= 0 && x < g.X && y < g.Y && z < g.Z
+}
+
+// Get returns the block at x, y, z, or air if it is out of bounds
+func (g *Grid) Get(x, y, z int) byte {
+	if !g.InBounds(x, y, z) {
+		return blocks.Air
+	}
+	return g.Blocks[(y*g.Z+z)*g.X+x]
+}
+
+// Set changes the block at x, y, z, does nothing if it is out of bounds
+func (g *Grid) Set(x, y, z int, b byte) {
+	if g.InBounds(x, y, z) {
+		g.Blocks[(y*g.Z+z)*g.X+x] = b
+	}
+}
+
+// fills the layers from y0 up to but not including y1
+func (g *Grid) fillLayers(y0, y1 int, b byte) {
+	for y := y0; y < y1; y++ {
+		for z := 0; z < g.Z; z++ {
+			for x := 0; x < g.X; x++ {
+				g.Set(x, y, z, b)
+			}
+		}
+	}
+}
+
+// ParseSeed turns a seed typed by a user into a number, words are hashed and an empty seed is random
+func ParseSeed(s string) int64 {
+	if s == "" {
+		return time.Now().UnixNano()
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return int64(h.Sum64())
+}
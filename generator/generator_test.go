@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"marmalade/blocks"
+)
+
+func TestDeterministic(t *testing.T) {
+	for _, name := range Names() {
+		a, err := Generate(name, "a", 64, 64, 64, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := Generate(name, "b", 64, 64, 64, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a.BlockArray, b.BlockArray) || a.Spawn != b.Spawn {
+			t.Fatalf("%v generated different maps from the same seed", name)
+		}
+	}
+
+	a, _ := Generate("natural", "a", 64, 64, 64, 1)
+	b, _ := Generate("natural", "b", 64, 64, 64, 2)
+	if bytes.Equal(a.BlockArray, b.BlockArray) {
+		t.Fatal("natural generated the same map from different seeds")
+	}
+}
+
+func TestNatural(t *testing.T) {
+	w, err := Generate("natural", "natural", 128, 64, 128, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := map[byte]int{}
+	for _, v := range w.BlockArray {
+		counts[v]++
+	}
+	for _, b := range []byte{blocks.Bedrock, blocks.Stone, blocks.Dirt, blocks.Grass, blocks.Water, blocks.Sand, blocks.Log, blocks.Leaves, blocks.CoalOre} {
+		if counts[b] == 0 {
+			t.Errorf("expected some %v", blocks.Name(b))
+		}
+	}
+	g := Grid{Blocks: w.BlockArray, X: 128, Y: 64, Z: 128}
+	if g.Get(int(w.Spawn.X), int(w.Spawn.Y), int(w.Spawn.Z)) != blocks.Air || g.Get(int(w.Spawn.X), int(w.Spawn.Y)-1, int(w.Spawn.Z)) == blocks.Air {
+		t.Fatal("expected the spawn to be on top of the ground")
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	if _, err := Generate("nope", "a", 64, 64, 64, 0); !errors.Is(err, UnknownGeneratorError) {
+		t.Fatalf("expected UnknownGeneratorError, got %v", err)
+	}
+	if _, err := Generate("flat", "a", 8, 64, 64, 0); !errors.Is(err, InvalidSizeError) {
+		t.Fatalf("expected InvalidSizeError, got %v", err)
+	}
+	if _, err := Generate("flat", "a", 1024, 256, 1024, 0); !errors.Is(err, InvalidSizeError) {
+		t.Fatalf("expected InvalidSizeError, got %v", err)
+	}
+}
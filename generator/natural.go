@@ -0,0 +1,137 @@
+package generator
+
+import (
+	"math"
+	"math/rand"
+
+	"marmalade/blocks"
+)
+
+// natural is hilly terrain with water, beaches, caves, ores, trees and flowers
+func natural(g *Grid, r *rand.Rand) {
+	heightNoise := newNoise(r)
+	roughNoise := newNoise(r)
+	caveNoiseA := newNoise(r)
+	caveNoiseB := newNoise(r)
+	forestNoise := newNoise(r)
+
+	waterLevel := g.Y / 2
+	heights := make([]int, g.X*g.Z)
+
+	// terrain
+	for z := 0; z < g.Z; z++ {
+		for x := 0; x < g.X; x++ {
+			fx, fz := float64(x), float64(z)
+			// rough areas are hillier than smooth ones
+			roughness := 0.5 + (roughNoise.fractal(fx/128, 0, fz/128, 2)+1)/2
+			h := waterLevel + int(heightNoise.fractal(fx/96, 0, fz/96, 5)*float64(g.Y)*roughness)
+			h = clamp(h, 2, g.Y-2)
+			heights[z*g.X+x] = h
+
+			beach := h <= waterLevel+1 && h >= waterLevel-1
+			for y := 0; y <= h; y++ {
+				var b byte
+				switch {
+				case y == 0:
+					b = blocks.Bedrock
+				case y < h-3:
+					b = blocks.Stone
+				case beach:
+					b = blocks.Sand
+				case y < h:
+					b = blocks.Dirt
+				case h < waterLevel:
+					b = blocks.Gravel // sea floor
+				default:
+					b = blocks.Grass
+				}
+				g.Set(x, y, z, b)
+			}
+			for y := h + 1; y <= waterLevel; y++ {
+				g.Set(x, y, z, blocks.Water)
+			}
+		}
+	}
+
+	// caves are where two noise fields are both close to zero, which makes winding tunnels
+	for z := 0; z < g.Z; z++ {
+		for x := 0; x < g.X; x++ {
+			h := heights[z*g.X+x]
+			if h <= waterLevel { // keep the sea from draining into caves
+				continue
+			}
+			for y := 1; y < h-1; y++ {
+				fx, fy, fz := float64(x)/32, float64(y)/24, float64(z)/32
+				if math.Abs(caveNoiseA.at(fx, fy, fz)) < 0.06 && math.Abs(caveNoiseB.at(fx, fy, fz)) < 0.06 {
+					if y < 6 {
+						g.Set(x, y, z, blocks.Lava)
+					} else {
+						g.Set(x, y, z, blocks.Air)
+					}
+				}
+			}
+		}
+	}
+
+	// ores, rarer ones only deep down
+	ores := []struct {
+		block byte
+		veins int // per 16x16 column of the map
+		size  int
+		maxY  int
+	}{
+		{blocks.CoalOre, 10, 8, g.Y * 3 / 4},
+		{blocks.IronOre, 6, 6, g.Y / 2},
+		{blocks.GoldOre, 2, 5, g.Y / 4},
+	}
+	columns := (g.X / 16) * (g.Z / 16)
+	for _, ore := range ores {
+		for i := 0; i < ore.veins*columns; i++ {
+			x, y, z := r.Intn(g.X), 1+r.Intn(max(ore.maxY-1, 1)), r.Intn(g.Z)
+			for j := 0; j < ore.size; j++ {
+				if g.Get(x, y, z) == blocks.Stone {
+					g.Set(x, y, z, ore.block)
+				}
+				x, y, z = x+r.Intn(3)-1, y+r.Intn(3)-1, z+r.Intn(3)-1
+			}
+		}
+	}
+
+	// plants, with trees in forests
+	for z := 2; z < g.Z-2; z++ {
+		for x := 2; x < g.X-2; x++ {
+			h := heights[z*g.X+x]
+			if g.Get(x, h, z) != blocks.Grass || g.Get(x, h+1, z) != blocks.Air {
+				continue
+			}
+			forest := forestNoise.fractal(float64(x)/64, 0, float64(z)/64, 2)
+			switch n := r.Intn(1000); {
+			case forest > 0.15 && n < 40, n < 3:
+				if Tree(g, x, h+1, z, r) {
+					g.Set(x, h, z, blocks.Dirt)
+				}
+			case n < 10:
+				g.Set(x, h+1, z, blocks.Dandelion)
+			case n < 15:
+				g.Set(x, h+1, z, blocks.Rose)
+			}
+		}
+	}
+}
+
+func clamp(i, lo, hi int) int {
+	if i < lo {
+		return lo
+	}
+	if i > hi {
+		return hi
+	}
+	return i
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
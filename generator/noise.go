@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"math"
+	"math/rand"
+)
+
+// noise is seeded improved Perlin noise, see https://mrl.cs.nyu.edu/~perlin/noise/
+type noise struct {
+	perm [512]uint8
+}
+
+func newNoise(r *rand.Rand) *noise {
+	n := new(noise)
+	for i, v := range r.Perm(256) {
+		n.perm[i] = uint8(v)
+		n.perm[i+256] = uint8(v)
+	}
+	return n
+}
+
+// at returns the noise at x, y, z, which is between -1 and 1
+func (n *noise) at(x, y, z float64) float64 {
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	xi, yi, zi := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z = x-fx, y-fy, z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+
+	p := &n.perm
+	a := int(p[xi]) + yi
+	aa, ab := int(p[a])+zi, int(p[a+1])+zi
+	b := int(p[xi+1]) + yi
+	ba, bb := int(p[b])+zi, int(p[b+1])+zi
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(p[aa], x, y, z), grad(p[ba], x-1, y, z)),
+			lerp(u, grad(p[ab], x, y-1, z), grad(p[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(p[aa+1], x, y, z-1), grad(p[ba+1], x-1, y, z-1)),
+			lerp(u, grad(p[ab+1], x, y-1, z-1), grad(p[bb+1], x-1, y-1, z-1))))
+}
+
+// fractal sums octaves of noise, each with twice the frequency and half the amplitude of the last
+// The result is between -1 and 1
+func (n *noise) fractal(x, y, z float64, octaves int) float64 {
+	sum, amplitude, total := 0.0, 1.0, 0.0
+	for i := 0; i < octaves; i++ {
+		sum += n.at(x, y, z) * amplitude
+		total += amplitude
+		x, y, z = x*2, y*2, z*2
+		amplitude /= 2
+	}
+	return sum / total
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+func grad(hash uint8, x, y, z float64) float64 {
+	h := hash & 15
+	u, v := y, z
+	if h < 8 {
+		u = x
+	}
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
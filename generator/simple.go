@@ -0,0 +1,34 @@
+package generator
+
+import (
+	"math/rand"
+
+	"marmalade/blocks"
+)
+
+// flat is grass on top of dirt, halfway up the map
+func flat(g *Grid, _ *rand.Rand) {
+	ground := g.Y / 2
+	g.fillLayers(0, 1, blocks.Bedrock)
+	g.fillLayers(1, ground-4, blocks.Stone)
+	g.fillLayers(ground-4, ground-1, blocks.Dirt)
+	g.fillLayers(ground-1, ground, blocks.Grass)
+}
+
+// empty is nothing but air
+func empty(*Grid, *rand.Rand) {}
+
+// canvas is a bedrock floor surrounded by white wool walls to make pixel art on
+func canvas(g *Grid, _ *rand.Rand) {
+	g.fillLayers(0, 1, blocks.Bedrock)
+	for y := 1; y < g.Y; y++ {
+		for x := 0; x < g.X; x++ {
+			g.Set(x, y, 0, blocks.WhiteWool)
+			g.Set(x, y, g.Z-1, blocks.WhiteWool)
+		}
+		for z := 0; z < g.Z; z++ {
+			g.Set(0, y, z, blocks.WhiteWool)
+			g.Set(g.X-1, y, z, blocks.WhiteWool)
+		}
+	}
+}
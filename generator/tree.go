@@ -0,0 +1,73 @@
+package generator
+
+import (
+	"math/rand"
+
+	"marmalade/blocks"
+)
+
+// BlockAccess is a map that trees can be grown in, such as a Grid
+type BlockAccess interface {
+	InBounds(x, y, z int) bool
+	Get(x, y, z int) byte
+	Set(x, y, z int, b byte)
+}
+
+// Tree grows a tree with its trunk starting at x, y, z, like the trees of Minecraft Classic
+// Returns false without changing anything if there isn't enough space for it
+func Tree(b BlockAccess, x, y, z int, r *rand.Rand) bool {
+	height := 4 + r.Intn(3)
+	top := y + height
+
+	// the trunk may replace the sapling it grows from, the rest of the space must be empty or leaves
+	for ty := y; ty <= top; ty++ {
+		radius := 0
+		if ty >= top-3 {
+			radius = 2
+		}
+		for tx := x - radius; tx <= x+radius; tx++ {
+			for tz := z - radius; tz <= z+radius; tz++ {
+				if !b.InBounds(tx, ty, tz) {
+					return false
+				}
+				switch b.Get(tx, ty, tz) {
+				case blocks.Air, blocks.Leaves:
+				case blocks.Sapling:
+					if tx != x || ty != y || tz != z {
+						return false
+					}
+				default:
+					return false
+				}
+			}
+		}
+	}
+
+	// two wide layers of leaves, then two narrow ones, with the corners left out at random
+	for ty := top - 3; ty <= top; ty++ {
+		radius := 2
+		if ty >= top-1 {
+			radius = 1
+		}
+		for tx := x - radius; tx <= x+radius; tx++ {
+			for tz := z - radius; tz <= z+radius; tz++ {
+				corner := abs(tx-x) == radius && abs(tz-z) == radius
+				if corner && (ty == top || r.Intn(2) == 0) {
+					continue
+				}
+				b.Set(tx, ty, tz, blocks.Leaves)
+			}
+		}
+	}
+	for ty := y; ty < top; ty++ {
+		b.Set(x, ty, z, blocks.Log)
+	}
+	return true
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
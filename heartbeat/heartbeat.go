@@ -0,0 +1,125 @@
+package heartbeat
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Info is what gets announced to the server list
+type Info struct {
+	Name     string
+	Port     int
+	Users    int
+	MaxUsers int
+	Public   bool
+	Salt     string
+	Software string
+}
+
+// Heartbeat periodically announces the server to a server list
+type Heartbeat struct {
+	URL        string
+	Interval   time.Duration
+	MaxBackoff time.Duration
+	Info       func() Info // called before every beat to get up to date values
+
+	client *http.Client
+	sleep  func(time.Duration)
+
+	mu      *sync.Mutex
+	playURL string
+}
+
+var ResponseError = errors.New("heartbeat: unexpected response")
+
+func New(url string, interval, maxBackoff time.Duration, info func() Info) *Heartbeat {
+	return &Heartbeat{
+		URL:        url,
+		Interval:   interval,
+		MaxBackoff: maxBackoff,
+		Info:       info,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		sleep:      time.Sleep,
+		mu:         new(sync.Mutex),
+	}
+}
+
+// PlayURL returns the url returned by the server list on the last successful beat
+func (h *Heartbeat) PlayURL() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.playURL
+}
+
+// Beat sends a single heartbeat
+func (h *Heartbeat) Beat() error {
+	info := h.Info()
+	form := url.Values{
+		"name":     {info.Name},
+		"port":     {strconv.Itoa(info.Port)},
+		"users":    {strconv.Itoa(info.Users)},
+		"max":      {strconv.Itoa(info.MaxUsers)},
+		"public":   {publicString(info.Public)},
+		"salt":     {info.Salt},
+		"software": {info.Software},
+		"version":  {"7"},
+	}
+	resp, respErr := h.client.PostForm(h.URL, form)
+	if respErr != nil {
+		return respErr
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, bodyErr := ioutil.ReadAll(resp.Body)
+	if bodyErr != nil {
+		return bodyErr
+	}
+	text := strings.TrimSpace(string(body))
+	// on success the server list answers with the play url, anything else is an error message
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(text, "http") {
+		return fmt.Errorf("%w, status %v: %v", ResponseError, resp.StatusCode, text)
+	}
+
+	h.mu.Lock()
+	changed := h.playURL != text
+	h.playURL = text
+	h.mu.Unlock()
+	if changed {
+		log.Printf("[INFO] Server list play url: %v", text)
+	}
+	return nil
+}
+
+// Run sends heartbeats forever, backing off exponentially (up to MaxBackoff) while they fail
+func (h *Heartbeat) Run() {
+	delay := h.Interval
+	for {
+		if err := h.Beat(); err != nil {
+			log.Printf("[ERROR] Heartbeat to %v failed, retrying in %v: %v", h.URL, delay, err)
+			h.sleep(delay)
+			delay *= 2
+			if delay > h.MaxBackoff {
+				delay = h.MaxBackoff
+			}
+			continue
+		}
+		delay = h.Interval
+		h.sleep(h.Interval)
+	}
+}
+
+// the classic heartbeat expects True or False
+func publicString(public bool) string {
+	if public {
+		return "True"
+	}
+	return "False"
+}
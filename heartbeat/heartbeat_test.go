@@ -0,0 +1,101 @@
+package heartbeat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testInfo() Info {
+	return Info{Name: "test server", Port: 25565, Users: 3, MaxUsers: 20, Public: true, Salt: "abcdefghijklmnop", Software: "marmalade"}
+}
+
+func TestBeat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		expected := map[string]string{
+			"name": "test server", "port": "25565", "users": "3", "max": "20",
+			"public": "True", "salt": "abcdefghijklmnop", "software": "marmalade",
+		}
+		for k, v := range expected {
+			if got := r.PostForm.Get(k); got != v {
+				t.Errorf("field %v: got `%v`, expected `%v`", k, got, v)
+			}
+		}
+		_, _ = fmt.Fprint(w, "http://example.com/play/123\n")
+	}))
+	defer server.Close()
+
+	h := New(server.URL, time.Minute, time.Minute, testInfo)
+	if err := h.Beat(); err != nil {
+		t.Fatal(err)
+	}
+	if h.PlayURL() != "http://example.com/play/123" {
+		t.Fatalf("unexpected play url `%v`", h.PlayURL())
+	}
+}
+
+func TestBeatError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"errors":[["Invalid port"]]}`)
+	}))
+	defer server.Close()
+
+	h := New(server.URL, time.Minute, time.Minute, testInfo)
+	if err := h.Beat(); !errors.Is(err, ResponseError) {
+		t.Fatalf("expected ResponseError, got %v", err)
+	}
+	if h.PlayURL() != "" {
+		t.Fatalf("play url should not be set, got `%v`", h.PlayURL())
+	}
+}
+
+func TestRunBackoff(t *testing.T) {
+	// the 6th beat succeeds, every other fails
+	var beats int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&beats, 1) == 6 {
+			_, _ = fmt.Fprint(w, "http://example.com/play/123")
+			return
+		}
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, time.Second, time.Second}
+	delays := make(chan time.Duration, len(expected))
+	h := New(server.URL, time.Second, 5*time.Second, testInfo)
+	h.sleep = func(d time.Duration) {
+		delays <- d
+		if len(delays) == len(expected) {
+			runtime.Goexit() // Run never returns by itself
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Run()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run didn't sleep as often as expected")
+	}
+	close(delays)
+
+	var got []time.Duration
+	for d := range delays {
+		got = append(got, d)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected the delays %v, got %v", expected, got)
+	}
+}
@@ -3,6 +3,11 @@ package helpers
 import (
 	"bufio"
 	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
 	"sync"
 )
 
@@ -69,3 +74,44 @@ func PartitionString(s string, size int) []string {
 	}
 	return buf
 }
+
+const saltAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// NewSalt returns a cryptographically random alphanumeric string of length n
+func NewSalt(n int) string {
+	buf := make([]byte, n)
+	max := big.NewInt(int64(len(saltAlphabet)))
+	for i := range buf {
+		// rand.Int is uniform, unlike a random byte modulo the length of the alphabet
+		v, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		buf[i] = saltAlphabet[v.Int64()]
+	}
+	return string(buf)
+}
+
+// LoadJSON decodes the file at path into v, a missing file is not an error and leaves v untouched
+func LoadJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// SaveJSON encodes v into the file at path, writing to a temporary file first so a crash can't leave it half written
+func SaveJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+"_TMP", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+"_TMP", path)
+}
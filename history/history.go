@@ -0,0 +1,257 @@
+// Package history keeps an append-only log of the block changes players make in a world
+package history
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Change is a block changed by a player
+type Change struct {
+	Time     time.Time
+	Player   string
+	X, Y, Z  uint16
+	Old, New byte
+	Revert   bool // whether the change undid an earlier one, such as with /undo
+}
+
+// The log is a sequence of records, each starting with its kind
+// Names are written once, the first time a player changes a block, and changes refer to them by their index
+const (
+	kindName   = 0 // length uint8, then the name
+	kindChange = 1 // unix seconds uint32, name index uint32, x, y, z uint16, old and new block type
+	kindRevert = 2 // the same as kindChange
+)
+
+const changeSize = 1 + 4 + 4 + 2*3 + 1 + 1
+
+var CorruptLogError = errors.New("history: corrupt log")
+
+type change struct {
+	time     uint32
+	player   uint32
+	x, y, z  uint16
+	old, new byte
+	revert   bool
+}
+
+// Log is the history of a world, kept in memory and appended to a file
+type Log struct {
+	mu      sync.Mutex
+	file    *os.File // nil if the log is only kept in memory
+	size    int64    // length of the records in file that were written completely
+	names   []string
+	ids     map[string]uint32 // by lowercase name
+	changes []change          // oldest first
+}
+
+// New creates a log that is only kept in memory
+func New() *Log {
+	return &Log{ids: map[string]uint32{}}
+}
+
+// Open loads the log at path, creating it if it doesn't exist, and appends further changes to it
+// A record cut off at the end, such as by a crash while writing it, is removed
+func Open(path string) (*Log, error) {
+	file, fileErr := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if fileErr != nil {
+		return nil, fileErr
+	}
+	data, dataErr := ioutil.ReadAll(file)
+	if dataErr != nil {
+		_ = file.Close()
+		return nil, dataErr
+	}
+
+	l := New()
+	valid, parseErr := l.parse(data)
+	if parseErr != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%w, %v: %v", CorruptLogError, path, parseErr)
+	}
+	if valid != len(data) {
+		if err := file.Truncate(int64(valid)); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	if _, err := file.Seek(int64(valid), io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	l.file = file
+	l.size = int64(valid)
+	return l, nil
+}
+
+// parses the records in data, returning the length of the complete ones
+func (l *Log) parse(data []byte) (int, error) {
+	i := 0
+	for i < len(data) {
+		switch data[i] {
+		case kindName:
+			if i+2 > len(data) || i+2+int(data[i+1]) > len(data) {
+				return i, nil
+			}
+			name := string(data[i+2 : i+2+int(data[i+1])])
+			l.ids[strings.ToLower(name)] = uint32(len(l.names))
+			l.names = append(l.names, name)
+			i += 2 + len(name)
+		case kindChange, kindRevert:
+			if i+changeSize > len(data) {
+				return i, nil
+			}
+			r := data[i+1:]
+			c := change{
+				time:   binary.BigEndian.Uint32(r),
+				player: binary.BigEndian.Uint32(r[4:]),
+				x:      binary.BigEndian.Uint16(r[8:]),
+				y:      binary.BigEndian.Uint16(r[10:]),
+				z:      binary.BigEndian.Uint16(r[12:]),
+				old:    r[14],
+				new:    r[15],
+				revert: data[i] == kindRevert,
+			}
+			if int(c.player) >= len(l.names) {
+				return i, fmt.Errorf("change at %v refers to unknown player %v", i, c.player)
+			}
+			l.changes = append(l.changes, c)
+			i += changeSize
+		default:
+			return i, fmt.Errorf("unknown record kind %v at %v", data[i], i)
+		}
+	}
+	return i, nil
+}
+
+// Add records changes, all written to the file at once
+// If writing fails, none of them are recorded, and what was written of them is removed from the file again
+func (l *Log) Add(changes ...Change) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, v := range changes {
+		if len(v.Player) > 255 {
+			return fmt.Errorf("history: player name %v is too long", v.Player)
+		}
+	}
+
+	// names are only known once they are written, so that no record can refer to a name missing from the file
+	var buf []byte
+	var names []string
+	ids := map[string]uint32{}
+	records := make([]change, 0, len(changes))
+	for _, v := range changes {
+		key := strings.ToLower(v.Player)
+		id, found := l.ids[key]
+		if !found {
+			id, found = ids[key]
+		}
+		if !found {
+			id = uint32(len(l.names) + len(names))
+			ids[key] = id
+			names = append(names, v.Player)
+			buf = append(buf, kindName, byte(len(v.Player)))
+			buf = append(buf, v.Player...)
+		}
+		c := change{time: uint32(v.Time.Unix()), player: id, x: v.X, y: v.Y, z: v.Z, old: v.Old, new: v.New, revert: v.Revert}
+		records = append(records, c)
+
+		var record [changeSize]byte
+		record[0] = kindChange
+		if c.revert {
+			record[0] = kindRevert
+		}
+		binary.BigEndian.PutUint32(record[1:], c.time)
+		binary.BigEndian.PutUint32(record[5:], c.player)
+		binary.BigEndian.PutUint16(record[9:], c.x)
+		binary.BigEndian.PutUint16(record[11:], c.y)
+		binary.BigEndian.PutUint16(record[13:], c.z)
+		record[15], record[16] = c.old, c.new
+		buf = append(buf, record[:]...)
+	}
+
+	if l.file != nil {
+		if n, err := l.file.Write(buf); err != nil {
+			if n > 0 {
+				l.truncate()
+			}
+			return err
+		}
+		l.size += int64(len(buf))
+	}
+	for k, v := range ids {
+		l.ids[k] = v
+	}
+	l.names = append(l.names, names...)
+	l.changes = append(l.changes, records...)
+	return nil
+}
+
+// removes a partly written record from the end of the file, must be called with mu held
+// If that fails, nothing more is written to the file, Open removes the partial record when it is loaded again
+func (l *Log) truncate() {
+	if err := l.file.Truncate(l.size); err == nil {
+		if _, err := l.file.Seek(l.size, io.SeekStart); err == nil {
+			return
+		}
+	}
+	_ = l.file.Close()
+	l.file = nil
+}
+
+// At returns up to n of the latest changes to the block at x, y, z, newest first
+func (l *Log) At(x, y, z uint16, n int) []Change {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []Change
+	for i := len(l.changes) - 1; i >= 0 && len(out) < n; i-- {
+		if c := l.changes[i]; c.x == x && c.y == y && c.z == z {
+			out = append(out, l.export(c))
+		}
+	}
+	return out
+}
+
+// By returns the changes of the player made at or after since, newest first
+// At most n are returned, n < 0 for no limit
+func (l *Log) By(player string, since time.Time, n int) []Change {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id, found := l.ids[strings.ToLower(player)]
+	if !found {
+		return nil
+	}
+	var out []Change
+	for i := len(l.changes) - 1; i >= 0 && len(out) != n; i-- {
+		c := l.changes[i]
+		if int64(c.time) < since.Unix() {
+			break
+		}
+		if c.player == id {
+			out = append(out, l.export(c))
+		}
+	}
+	return out
+}
+
+func (l *Log) export(c change) Change {
+	return Change{Time: time.Unix(int64(c.time), 0), Player: l.names[c.player], X: c.x, Y: c.y, Z: c.z, Old: c.old, New: c.new, Revert: c.revert}
+}
+
+// Close closes the file of the log
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,116 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.history")
+	now := time.Unix(time.Now().Unix(), 0)
+
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Add(
+		Change{Time: now.Add(-time.Hour), Player: "alice", X: 1, Y: 2, Z: 3, Old: 0, New: 1},
+		Change{Time: now, Player: "bob", X: 1, Y: 2, Z: 3, Old: 1, New: 0},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Add(Change{Time: now, Player: "Alice", X: 4, Y: 5, Z: 6, Old: 0, New: 4, Revert: true}); err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Close()
+
+	// a change cut off by a crash
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte{kindChange, 0, 0})
+	_ = f.Close()
+
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	at := l.At(1, 2, 3, 10)
+	if len(at) != 2 || at[0].Player != "bob" || at[1].Player != "alice" || !at[1].Time.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("wrong changes at 1 2 3: %+v", at)
+	}
+	by := l.By("ALICE", time.Time{}, -1)
+	if len(by) != 2 || by[0] != (Change{Time: now, Player: "alice", X: 4, Y: 5, Z: 6, Old: 0, New: 4, Revert: true}) {
+		t.Fatalf("wrong changes by alice: %+v", by)
+	}
+	if recent := l.By("alice", now.Add(-time.Minute), -1); len(recent) != 1 {
+		t.Fatalf("expected 1 recent change by alice, got %v", len(recent))
+	}
+
+	// appending continues after the last complete record
+	if err := l.Add(Change{Time: now, Player: "carol", X: 1, Y: 2, Z: 3, Old: 0, New: 5}); err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Close()
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at := l.At(1, 2, 3, 1); len(at) != 1 || at[0].Player != "carol" {
+		t.Fatalf("change after reopening wasn't kept: %+v", at)
+	}
+}
+
+func TestLogCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corrupt.history")
+	if err := ioutil.WriteFile(path, []byte{7, 7, 7}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Fatal("opened a corrupt log")
+	}
+}
+
+func TestLogWriteFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.history")
+	now := time.Unix(time.Now().Unix(), 0)
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+
+	// writes to a read only file fail, so bob's name never makes it to the file
+	writable := l.file
+	readOnly, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.file = readOnly
+	if err := l.Add(Change{Time: now, Player: "bob", X: 1, Y: 2, Z: 3, New: 1}); err == nil {
+		t.Fatal("writing to a read only file succeeded")
+	}
+	_ = readOnly.Close()
+	l.file = writable
+	if len(l.By("bob", time.Time{}, -1)) != 0 {
+		t.Fatal("a change that failed to be written was recorded")
+	}
+
+	if err := l.Add(Change{Time: now, Player: "bob", X: 1, Y: 2, Z: 3, New: 1}); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = reopened.Close() }()
+	if got := reopened.By("bob", time.Time{}, -1); len(got) != 1 {
+		t.Fatalf("expected bob's change after reopening, got %+v", got)
+	}
+}
@@ -0,0 +1,157 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/classicworld"
+)
+
+const (
+	datMagic = 0x271bb788
+
+	// the size of every map before Minecraft Classic 0.0.13a
+	rawX, rawY, rawZ = 256, 64, 256
+)
+
+// ReadDat reads a Minecraft Classic level, in one of three formats, all gzip compressed
+//
+//	Before 0.0.13a: only the blocks of a 256x64x256 map
+//	Version 1: magic, version, name and creator (Java UTF strings), creation time (int64 milliseconds),
+//	           width, length, height (int16), and the blocks
+//	Version 2: magic, version, and a serialized com.mojang.minecraft.level.Level object
+//
+// Numbers are big endian, and blocks are in the same order as ClassicWorld
+func ReadDat(r io.Reader) (*classicworld.World, error) {
+	bufR, err := gunzip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if magic, _ := bufR.Peek(4); len(magic) < 4 || binary.BigEndian.Uint32(magic) != datMagic {
+		w, err := newWorld(rawX, rawY, rawZ)
+		if err != nil {
+			return nil, err
+		}
+		if err := readBlocks(bufR, w); err != nil {
+			return nil, err
+		}
+		w.Spawn = spawnOnTop(w)
+		return w, nil
+	}
+
+	var header struct {
+		Magic   uint32
+		Version uint8
+	}
+	if err := binary.Read(bufR, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	j := &javaReader{r: bufR}
+	switch header.Version {
+	case 1:
+		name, err := j.readUTF(false)
+		if err != nil {
+			return nil, err
+		}
+		creator, err := j.readUTF(false)
+		if err != nil {
+			return nil, err
+		}
+		var rest struct {
+			CreateTime int64
+			X, Z, Y    int16
+		}
+		if err := binary.Read(bufR, binary.BigEndian, &rest); err != nil {
+			return nil, err
+		}
+		w, err := newWorld(int(rest.X), int(rest.Y), int(rest.Z))
+		if err != nil {
+			return nil, err
+		}
+		if err := readBlocks(bufR, w); err != nil {
+			return nil, err
+		}
+		setClassicInfo(w, name, creator, rest.CreateTime)
+		w.Spawn = spawnOnTop(w)
+		return w, nil
+	case 2:
+		return readSerializedLevel(bufR)
+	default:
+		return nil, fmt.Errorf("%w, unknown Minecraft Classic level version %v", InvalidMapError, header.Version)
+	}
+}
+
+func readSerializedLevel(r *bufio.Reader) (*classicworld.World, error) {
+	j, err := newJavaReader(r)
+	if err != nil {
+		return nil, err
+	}
+	level, err := j.readObject()
+	if err != nil {
+		return nil, err
+	}
+
+	// height is the length of the map, and depth is what everyone else calls its height
+	x, xOk := level.fields["width"].(int32)
+	y, yOk := level.fields["depth"].(int32)
+	z, zOk := level.fields["height"].(int32)
+	b, bOk := level.fields["blocks"].([]byte)
+	if !xOk || !yOk || !zOk || !bOk {
+		return nil, fmt.Errorf("%w, %v has no size or blocks", InvalidMapError, level.class.name)
+	}
+	w, err := newWorld(int(x), int(y), int(z))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != len(w.BlockArray) {
+		return nil, fmt.Errorf("%w, has %v blocks, but is %vx%vx%v", InvalidMapError, len(b), x, y, z)
+	}
+	for i, v := range b {
+		w.BlockArray[i] = convertBlock(v)
+	}
+
+	name, _ := level.fields["name"].(string)
+	creator, _ := level.fields["creator"].(string)
+	createTime, _ := level.fields["createTime"].(int64)
+	setClassicInfo(w, name, creator, createTime)
+
+	w.Spawn = spawnOnTop(w)
+	sx, sxOk := level.fields["xSpawn"].(int32)
+	sy, syOk := level.fields["ySpawn"].(int32)
+	sz, szOk := level.fields["zSpawn"].(int32)
+	if sxOk && syOk && szOk && sx >= 0 && sy >= 0 && sz >= 0 && sx < x && sy < y && sz < z {
+		w.Spawn = classicworld.Spawn{X: uint16(sx), Y: uint16(sy), Z: uint16(sz)}
+		if rot, ok := level.fields["rotSpawn"].(float32); ok {
+			w.Spawn.H = uint8(int(rot*256/360) & 0xff)
+		}
+	}
+	return w, nil
+}
+
+func setClassicInfo(w *classicworld.World, name, creator string, createTime int64) {
+	w.Name = name
+	if creator != "" {
+		w.CreatedBy = classicworld.CreatedBy{Service: "Minecraft Classic", Username: creator}
+	}
+	if createTime > 0 {
+		w.TimeCreated = time.Unix(0, createTime*int64(time.Millisecond))
+	}
+}
+
+// spawnOnTop puts the spawn on the highest block in the middle of the map, for maps that don't have a spawn
+func spawnOnTop(w *classicworld.World) classicworld.Spawn {
+	x, z := int(w.X)/2, int(w.Z)/2
+	y := int(w.Y)
+	for y > 0 && w.BlockArray[((y-1)*int(w.Z)+z)*int(w.X)+x] == blocks.Air {
+		y--
+	}
+	if y >= int(w.Y) {
+		y = int(w.Y) - 1
+	}
+	return classicworld.Spawn{X: uint16(x), Y: uint16(y), Z: uint16(z)}
+}
@@ -0,0 +1,149 @@
+// Package importer converts maps of other servers and Minecraft Classic itself into ClassicWorld maps
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/classicworld"
+)
+
+var (
+	UnknownFormatError = errors.New("unknown map format")
+	InvalidMapError    = errors.New("invalid map")
+)
+
+// maps with more blocks than this are refused instead of trying to allocate them
+const maxVolume = 1024 * 256 * 1024
+
+// Extensions lists the file extensions Import understands
+var Extensions = []string{".lvl", ".dat", ".mine", ".cw", ".ucw"}
+
+// Import reads the map at path, picking the format by the file extension
+//
+//	.lvl  MCSharp, MCLawl, MCForge and MCGalaxy levels
+//	.dat  Minecraft Classic levels, including the earliest ones that are only blocks
+//	.mine Minecraft Classic levels, the same as .dat
+//	.cw   ClassicWorld maps, gzip compressed or not, as is
+func Import(path string) (*classicworld.World, error) {
+	file, fileErr := os.Open(path)
+	if fileErr != nil {
+		return nil, fileErr
+	}
+	defer func() { _ = file.Close() }()
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var w *classicworld.World
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".lvl":
+		w, err = ReadLvl(file)
+	case ".dat", ".mine":
+		w, err = ReadDat(file)
+	case ".cw", ".ucw":
+		c, order, _, cErr := classicworld.Read(file)
+		if cErr != nil {
+			return nil, cErr
+		}
+		w, err = classicworld.Decode(c, order)
+	default:
+		return nil, fmt.Errorf("%w `%v`", UnknownFormatError, filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if w.Name == "" {
+		w.Name = name
+	}
+	return w, nil
+}
+
+// gunzip decompresses r if it starts with the gzip magic bytes, so that files someone decompressed by hand still load
+func gunzip(r io.Reader) (*bufio.Reader, error) {
+	bufR := bufio.NewReader(r)
+	if magic, _ := bufR.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return bufR, nil
+	}
+	gzipR, err := gzip.NewReader(bufR)
+	if err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(gzipR), nil
+}
+
+// newWorld creates a map of the given size, refusing sizes that can't be real
+func newWorld(x, y, z int) (*classicworld.World, error) {
+	if x <= 0 || y <= 0 || z <= 0 || x > 0xffff || y > 0xffff || z > 0xffff || x*y*z > maxVolume {
+		return nil, fmt.Errorf("%w, bad size %vx%vx%v", InvalidMapError, x, y, z)
+	}
+	w := classicworld.New("", uint16(x), uint16(y), uint16(z))
+	w.TimeCreated = time.Time{} // unknown unless the format says
+	return w, nil
+}
+
+// readBlocks fills the map's BlockArray from r, converting blocks this server doesn't know
+func readBlocks(r io.Reader, w *classicworld.World) error {
+	if _, err := io.ReadFull(r, w.BlockArray); err != nil {
+		return err
+	}
+	for i, v := range w.BlockArray {
+		w.BlockArray[i] = convertBlock(v)
+	}
+	return nil
+}
+
+// what the blocks of the CustomBlocks CPE extension (50 to 65) look like to clients without it
+var cpeFallbacks = [...]byte{
+	blocks.Slab,          // cobblestone slab
+	blocks.BrownMushroom, // rope
+	blocks.Sand,          // sandstone
+	blocks.Air,           // snow
+	blocks.FlowingLava,   // fire
+	blocks.PinkWool,      // light pink wool
+	blocks.GreenWool,     // forest green wool
+	blocks.Dirt,          // brown wool
+	blocks.PurpleWool,    // deep blue
+	blocks.BlueWool,      // turquoise
+	blocks.Glass,         // ice
+	blocks.IronBlock,     // ceramic tile
+	blocks.Obsidian,      // magma
+	blocks.WhiteWool,     // pillar
+	blocks.Planks,        // crate
+	blocks.Stone,         // stone brick
+}
+
+// the "op" blocks of MCSharp and its descendants, which only operators can break
+var opBlocks = map[byte]byte{
+	100: blocks.Glass,
+	101: blocks.Obsidian,
+	102: blocks.Bricks,
+	103: blocks.Stone,
+	104: blocks.Cobblestone,
+	105: blocks.Air,
+	106: blocks.Water,
+	107: blocks.Lava,
+}
+
+// convertBlock turns blocks from other servers into the nearest Minecraft Classic block
+// Special blocks, such as doors and custom blocks, become stone
+func convertBlock(b byte) byte {
+	switch {
+	case b < blocks.Count:
+		return b
+	case int(b) < blocks.Count+len(cpeFallbacks):
+		return cpeFallbacks[int(b)-blocks.Count]
+	}
+	if v, found := opBlocks[b]; found {
+		return v
+	}
+	return blocks.Stone
+}
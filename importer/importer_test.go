@@ -0,0 +1,252 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"marmalade/blocks"
+)
+
+func gzipped(t *testing.T, b []byte) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+// 4 wide, 2 high and 3 long, with a block of each type starting at 0 and wrapping around at 70
+func testBlocks() []byte {
+	b := make([]byte, 4*2*3)
+	for i := range b {
+		b[i] = byte(i * 3 % 70)
+	}
+	return b
+}
+
+func checkBlocks(t *testing.T, got []byte) {
+	for i, v := range testBlocks() {
+		if got[i] != convertBlock(v) {
+			t.Fatalf("block %v is %v, expected %v", i, got[i], convertBlock(v))
+		}
+	}
+}
+
+func TestReadLvl(t *testing.T) {
+	for _, magic := range []bool{true, false} {
+		buf := new(bytes.Buffer)
+		if magic {
+			_ = binary.Write(buf, binary.LittleEndian, uint16(lvlMagic))
+		}
+		_ = binary.Write(buf, binary.LittleEndian, []uint16{4, 3, 2, 1, 2, 1})
+		buf.Write([]byte{64, 0})
+		if magic {
+			buf.Write([]byte{0, 0}) // permissions
+		}
+		buf.Write(testBlocks())
+		buf.Write([]byte{0xbd, 1, 2, 3}) // custom blocks, which are ignored
+
+		w, err := ReadLvl(gzipped(t, buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.X != 4 || w.Y != 2 || w.Z != 3 || w.Spawn.X != 1 || w.Spawn.Y != 1 || w.Spawn.Z != 2 || w.Spawn.H != 64 {
+			t.Fatalf("wrong header %+v", w)
+		}
+		checkBlocks(t, w.BlockArray)
+	}
+}
+
+func TestReadDatVersion1(t *testing.T) {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, uint32(datMagic))
+	buf.WriteByte(1)
+	writeUTF(buf, "A Nice World")
+	writeUTF(buf, "notch")
+	_ = binary.Write(buf, binary.BigEndian, int64(1243814400000))
+	_ = binary.Write(buf, binary.BigEndian, []int16{4, 3, 2})
+	buf.Write(testBlocks())
+
+	w, err := ReadDat(gzipped(t, buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Name != "A Nice World" || w.CreatedBy.Username != "notch" || w.TimeCreated.Unix() != 1243814400 || w.X != 4 || w.Y != 2 || w.Z != 3 {
+		t.Fatalf("wrong header %+v", w)
+	}
+	checkBlocks(t, w.BlockArray)
+}
+
+func TestReadDatRaw(t *testing.T) {
+	b := make([]byte, rawX*rawY*rawZ)
+	for i := 0; i < rawX*rawZ*10; i++ {
+		b[i] = blocks.Stone
+	}
+	w, err := ReadDat(gzipped(t, b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.X != rawX || w.Y != rawY || w.Z != rawZ || w.Spawn.Y != 10 {
+		t.Fatalf("wrong size or spawn %v %v %v %+v", w.X, w.Y, w.Z, w.Spawn)
+	}
+}
+
+func TestReadDatVersion2(t *testing.T) {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, uint32(datMagic))
+	buf.WriteByte(2)
+	_ = binary.Write(buf, binary.BigEndian, []uint16{javaStreamMagic, javaStreamVersion})
+
+	// a Level with a list of entities before its blocks, like the real thing
+	buf.WriteByte(tcObject)
+	buf.WriteByte(tcClassDesc)
+	writeUTF(buf, "com.mojang.minecraft.level.Level")
+	_ = binary.Write(buf, binary.BigEndian, int64(1))
+	buf.WriteByte(scSerializable)
+	fields := []struct {
+		typeCode byte
+		name     string
+		class    string
+	}{
+		{'J', "createTime", ""}, {'I', "depth", ""}, {'I', "height", ""}, {'F', "rotSpawn", ""}, {'I', "width", ""},
+		{'I', "xSpawn", ""}, {'I', "ySpawn", ""}, {'I', "zSpawn", ""},
+		{'L', "blockMap", "Ljava/util/ArrayList;"}, {'[', "blocks", "[B"}, {'L', "creator", "Ljava/lang/String;"}, {'L', "name", "Ljava/lang/String;"},
+	}
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(fields)))
+	for _, f := range fields {
+		buf.WriteByte(f.typeCode)
+		writeUTF(buf, f.name)
+		if f.class != "" {
+			buf.WriteByte(tcString)
+			writeUTF(buf, f.class)
+		}
+	}
+	buf.WriteByte(tcEndBlockData)
+	buf.WriteByte(tcNull) // no superclass
+	_ = binary.Write(buf, binary.BigEndian, int64(1243814400000))
+	_ = binary.Write(buf, binary.BigEndian, []int32{2, 3})
+	_ = binary.Write(buf, binary.BigEndian, float32(90))
+	_ = binary.Write(buf, binary.BigEndian, []int32{4, 1, 1, 2})
+	// blockMap, an ArrayList, which has a custom writeObject method
+	buf.WriteByte(tcObject)
+	buf.WriteByte(tcClassDesc)
+	writeUTF(buf, "java.util.ArrayList")
+	_ = binary.Write(buf, binary.BigEndian, int64(2))
+	buf.WriteByte(scSerializable | scWriteMethod)
+	_ = binary.Write(buf, binary.BigEndian, uint16(1))
+	buf.WriteByte('I')
+	writeUTF(buf, "size")
+	buf.WriteByte(tcEndBlockData)
+	buf.WriteByte(tcNull)
+	_ = binary.Write(buf, binary.BigEndian, int32(1))
+	buf.Write([]byte{tcBlockData, 4, 0, 0, 0, 1})
+	buf.WriteByte(tcString)
+	writeUTF(buf, "an entity")
+	buf.WriteByte(tcEndBlockData)
+	// blocks
+	buf.WriteByte(tcArray)
+	buf.WriteByte(tcClassDesc)
+	writeUTF(buf, "[B")
+	_ = binary.Write(buf, binary.BigEndian, int64(3))
+	buf.WriteByte(scSerializable)
+	_ = binary.Write(buf, binary.BigEndian, uint16(0))
+	buf.WriteByte(tcEndBlockData)
+	buf.WriteByte(tcNull)
+	_ = binary.Write(buf, binary.BigEndian, int32(len(testBlocks())))
+	buf.Write(testBlocks())
+	// creator and name
+	buf.WriteByte(tcString)
+	writeUTF(buf, "notch")
+	buf.WriteByte(tcString)
+	writeUTF(buf, "A Nice World")
+
+	w, err := ReadDat(gzipped(t, buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Name != "A Nice World" || w.CreatedBy.Username != "notch" || w.X != 4 || w.Y != 2 || w.Z != 3 {
+		t.Fatalf("wrong level %+v", w)
+	}
+	if w.Spawn.X != 1 || w.Spawn.Y != 1 || w.Spawn.Z != 2 || w.Spawn.H != 64 {
+		t.Fatalf("wrong spawn %+v", w.Spawn)
+	}
+	checkBlocks(t, w.BlockArray)
+}
+
+func TestConvertBlock(t *testing.T) {
+	for b, expected := range map[byte]byte{blocks.Obsidian: blocks.Obsidian, 50: blocks.Slab, 65: blocks.Stone, 100: blocks.Glass, 200: blocks.Stone} {
+		if got := convertBlock(b); got != expected {
+			t.Errorf("converted %v into %v, expected %v", b, got, expected)
+		}
+	}
+}
+
+func writeUTF(buf *bytes.Buffer, s string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+func javaStream(content func(buf *bytes.Buffer)) *bufio.Reader {
+	buf := new(bytes.Buffer)
+	_ = binary.Write(buf, binary.BigEndian, []uint16{javaStreamMagic, javaStreamVersion})
+	content(buf)
+	return bufio.NewReader(buf)
+}
+
+func writeClassDesc(buf *bytes.Buffer, name string) {
+	buf.WriteByte(tcClassDesc)
+	writeUTF(buf, name)
+	_ = binary.Write(buf, binary.BigEndian, int64(1))
+	buf.WriteByte(scSerializable)
+	_ = binary.Write(buf, binary.BigEndian, uint16(0))
+	buf.WriteByte(tcEndBlockData)
+}
+
+func TestJavaCyclicSuperclass(t *testing.T) {
+	r := javaStream(func(buf *bytes.Buffer) {
+		buf.WriteByte(tcObject)
+		writeClassDesc(buf, "Loop")
+		buf.WriteByte(tcReference)
+		_ = binary.Write(buf, binary.BigEndian, uint32(javaBaseHandle)) // the class itself
+	})
+	j, err := newJavaReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.readObject(); !errors.Is(err, JavaSerializationError) {
+		t.Fatalf("expected JavaSerializationError, got %v", err)
+	}
+}
+
+func TestJavaNestingLimit(t *testing.T) {
+	r := javaStream(func(buf *bytes.Buffer) {
+		// arrays of arrays, far deeper than anything real
+		for i := 0; i < 10000; i++ {
+			buf.WriteByte(tcArray)
+			if i == 0 {
+				writeClassDesc(buf, "[Ljava.lang.Object;")
+				buf.WriteByte(tcNull)
+			} else {
+				buf.WriteByte(tcReference)
+				_ = binary.Write(buf, binary.BigEndian, uint32(javaBaseHandle))
+			}
+			_ = binary.Write(buf, binary.BigEndian, int32(1))
+		}
+		buf.WriteByte(tcNull)
+	})
+	j, err := newJavaReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.readContent(); !errors.Is(err, JavaSerializationError) {
+		t.Fatalf("expected JavaSerializationError, got %v", err)
+	}
+}
@@ -0,0 +1,429 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// A minimal reader of the Java object serialization stream format, enough to read the fields of serialized objects
+// See https://docs.oracle.com/javase/8/docs/platform/serialization/spec/protocol.html
+
+const (
+	javaStreamMagic   = 0xaced
+	javaStreamVersion = 5
+	javaBaseHandle    = 0x7e0000
+
+	tcNull           = 0x70
+	tcReference      = 0x71
+	tcClassDesc      = 0x72
+	tcObject         = 0x73
+	tcString         = 0x74
+	tcArray          = 0x75
+	tcClass          = 0x76
+	tcBlockData      = 0x77
+	tcEndBlockData   = 0x78
+	tcReset          = 0x79
+	tcBlockDataLong  = 0x7a
+	tcException      = 0x7b
+	tcLongString     = 0x7c
+	tcProxyClassDesc = 0x7d
+	tcEnum           = 0x7e
+
+	scWriteMethod    = 0x01
+	scSerializable   = 0x02
+	scExternalizable = 0x04
+	scBlockData      = 0x08
+
+	javaMaxDepth = 100 // objects, arrays and class descriptions nested deeper than this are refused
+)
+
+var JavaSerializationError = errors.New("unsupported java serialization stream")
+
+type (
+	javaClass struct {
+		name   string
+		flags  byte
+		fields []javaField
+		super  *javaClass
+	}
+
+	javaField struct {
+		typeCode byte
+		name     string
+	}
+
+	// javaObject holds the serialized fields of an object and its superclasses
+	javaObject struct {
+		class  *javaClass
+		fields map[string]interface{}
+	}
+
+	// an enum constant, which is only kept as its name
+	javaEnum string
+)
+
+// marks the end of an annotation
+var endBlockData = new(struct{})
+
+type javaReader struct {
+	r       *bufio.Reader
+	handles []interface{}
+	depth   int // of readContent calls, see javaMaxDepth
+}
+
+func newJavaReader(r *bufio.Reader) (*javaReader, error) {
+	var header struct{ Magic, Version uint16 }
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != javaStreamMagic || header.Version != javaStreamVersion {
+		return nil, fmt.Errorf("%w, bad header %x %v", JavaSerializationError, header.Magic, header.Version)
+	}
+	return &javaReader{r: r}, nil
+}
+
+// readObject reads the next object of the stream, which has to be a serialized object
+func (j *javaReader) readObject() (*javaObject, error) {
+	v, err := j.readContent()
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := v.(*javaObject)
+	if !ok {
+		return nil, fmt.Errorf("%w, expected an object, got %T", JavaSerializationError, v)
+	}
+	return obj, nil
+}
+
+func (j *javaReader) newHandle(v interface{}) int {
+	j.handles = append(j.handles, v)
+	return len(j.handles) - 1
+}
+
+func (j *javaReader) readContent() (interface{}, error) {
+	if j.depth >= javaMaxDepth {
+		return nil, fmt.Errorf("%w, nested deeper than %v", JavaSerializationError, javaMaxDepth)
+	}
+	j.depth++
+	defer func() { j.depth-- }()
+
+	tc, err := j.r.ReadByte()
+	for err == nil && tc == tcReset {
+		j.handles = nil
+		tc, err = j.r.ReadByte()
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch tc {
+	case tcNull:
+		return nil, nil
+	case tcReference:
+		var handle uint32
+		if err := binary.Read(j.r, binary.BigEndian, &handle); err != nil {
+			return nil, err
+		}
+		i := int(handle) - javaBaseHandle
+		if i < 0 || i >= len(j.handles) {
+			return nil, fmt.Errorf("%w, bad handle %x", JavaSerializationError, handle)
+		}
+		return j.handles[i], nil
+	case tcClassDesc:
+		return j.readNewClassDesc()
+	case tcObject:
+		return j.readNewObject()
+	case tcString, tcLongString:
+		s, err := j.readUTF(tc == tcLongString)
+		if err != nil {
+			return nil, err
+		}
+		j.newHandle(s)
+		return s, nil
+	case tcArray:
+		return j.readNewArray()
+	case tcClass:
+		class, err := j.readClassDesc()
+		if err != nil {
+			return nil, err
+		}
+		j.newHandle(class)
+		return class, nil
+	case tcEnum:
+		if _, err := j.readClassDesc(); err != nil {
+			return nil, err
+		}
+		h := j.newHandle(nil)
+		name, err := j.readContent()
+		if err != nil {
+			return nil, err
+		}
+		s, _ := name.(string)
+		j.handles[h] = javaEnum(s)
+		return javaEnum(s), nil
+	case tcBlockData, tcBlockDataLong:
+		// raw data written by custom writeObject methods, which we can't make sense of
+		var n uint32
+		if tc == tcBlockData {
+			b, err := j.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			n = uint32(b)
+		} else if err := binary.Read(j.r, binary.BigEndian, &n); err != nil {
+			return nil, err
+		}
+		_, err := io.CopyN(ioutil.Discard, j.r, int64(n))
+		return nil, err
+	case tcEndBlockData:
+		return endBlockData, nil
+	default: // tcException, tcProxyClassDesc and garbage
+		return nil, fmt.Errorf("%w, unsupported type code %x", JavaSerializationError, tc)
+	}
+}
+
+func (j *javaReader) readClassDesc() (*javaClass, error) {
+	v, err := j.readContent()
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, nil
+	}
+	class, ok := v.(*javaClass)
+	if !ok {
+		return nil, fmt.Errorf("%w, expected a class description, got %T", JavaSerializationError, v)
+	}
+	return class, nil
+}
+
+func (j *javaReader) readNewClassDesc() (*javaClass, error) {
+	name, err := j.readUTF(false)
+	if err != nil {
+		return nil, err
+	}
+	var serialVersionUID int64
+	if err := binary.Read(j.r, binary.BigEndian, &serialVersionUID); err != nil {
+		return nil, err
+	}
+	class := &javaClass{name: name}
+	j.newHandle(class)
+
+	var fieldCount uint16
+	if class.flags, err = j.r.ReadByte(); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(j.r, binary.BigEndian, &fieldCount); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(fieldCount); i++ {
+		var f javaField
+		if f.typeCode, err = j.r.ReadByte(); err != nil {
+			return nil, err
+		}
+		if f.name, err = j.readUTF(false); err != nil {
+			return nil, err
+		}
+		if f.typeCode == '[' || f.typeCode == 'L' {
+			if _, err := j.readContent(); err != nil { // the class name of the field
+				return nil, err
+			}
+		}
+		class.fields = append(class.fields, f)
+	}
+	if err := j.skipAnnotation(); err != nil {
+		return nil, err
+	}
+	if class.super, err = j.readClassDesc(); err != nil {
+		return nil, err
+	}
+	// the class is registered before its superclass is read, so a crafted stream can make it its own superclass
+	seen := map[*javaClass]bool{}
+	for c := class; c != nil; c = c.super {
+		if seen[c] {
+			return nil, fmt.Errorf("%w, class %v is its own superclass", JavaSerializationError, class.name)
+		}
+		seen[c] = true
+	}
+	return class, nil
+}
+
+// reads until the end of an annotation written by a custom writeObject method or class annotation
+func (j *javaReader) skipAnnotation() error {
+	for {
+		v, err := j.readContent()
+		if err != nil {
+			return err
+		}
+		if v == endBlockData {
+			return nil
+		}
+	}
+}
+
+func (j *javaReader) readNewObject() (*javaObject, error) {
+	class, err := j.readClassDesc()
+	if err != nil {
+		return nil, err
+	}
+	if class == nil {
+		return nil, fmt.Errorf("%w, object without a class", JavaSerializationError)
+	}
+	obj := &javaObject{class: class, fields: map[string]interface{}{}}
+	j.newHandle(obj)
+
+	// the data of superclasses comes first
+	var chain []*javaClass
+	for c := class; c != nil; c = c.super {
+		chain = append([]*javaClass{c}, chain...)
+	}
+	for _, c := range chain {
+		switch {
+		case c.flags&scExternalizable != 0:
+			if c.flags&scBlockData == 0 {
+				return nil, fmt.Errorf("%w, old externalizable class %v", JavaSerializationError, c.name)
+			}
+			if err := j.skipAnnotation(); err != nil {
+				return nil, err
+			}
+		case c.flags&scSerializable != 0:
+			// this assumes custom writeObject methods call defaultWriteObject first, which almost all do
+			for _, f := range c.fields {
+				v, err := j.readValue(f.typeCode)
+				if err != nil {
+					return nil, err
+				}
+				obj.fields[f.name] = v
+			}
+			if c.flags&scWriteMethod != 0 {
+				if err := j.skipAnnotation(); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return obj, nil
+}
+
+func (j *javaReader) readNewArray() (interface{}, error) {
+	class, err := j.readClassDesc()
+	if err != nil {
+		return nil, err
+	}
+	if class == nil || len(class.name) < 2 || class.name[0] != '[' {
+		return nil, fmt.Errorf("%w, array without an array class", JavaSerializationError)
+	}
+	h := j.newHandle(nil)
+	var size int32
+	if err := binary.Read(j.r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("%w, negative array size %v", JavaSerializationError, size)
+	}
+
+	var out interface{}
+	if elemType := class.name[1]; elemType == 'B' {
+		b := make([]byte, 0, minInt(int(size), 1<<20))
+		buf := make([]byte, 64*1024)
+		for remaining := int(size); remaining > 0; {
+			n := minInt(remaining, len(buf))
+			if _, err := io.ReadFull(j.r, buf[:n]); err != nil {
+				return nil, err
+			}
+			b = append(b, buf[:n]...)
+			remaining -= n
+		}
+		out = b
+	} else {
+		values := make([]interface{}, 0, minInt(int(size), 4096))
+		for i := 0; i < int(size); i++ {
+			v, err := j.readValue(elemType)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		out = values
+	}
+	j.handles[h] = out
+	return out, nil
+}
+
+// reads a field or array element value with the given type code
+func (j *javaReader) readValue(typeCode byte) (interface{}, error) {
+	var err error
+	switch typeCode {
+	case 'B':
+		var v int8
+		err = binary.Read(j.r, binary.BigEndian, &v)
+		return v, err
+	case 'C':
+		var v uint16
+		err = binary.Read(j.r, binary.BigEndian, &v)
+		return v, err
+	case 'D':
+		var v uint64
+		err = binary.Read(j.r, binary.BigEndian, &v)
+		return math.Float64frombits(v), err
+	case 'F':
+		var v uint32
+		err = binary.Read(j.r, binary.BigEndian, &v)
+		return math.Float32frombits(v), err
+	case 'I':
+		var v int32
+		err = binary.Read(j.r, binary.BigEndian, &v)
+		return v, err
+	case 'J':
+		var v int64
+		err = binary.Read(j.r, binary.BigEndian, &v)
+		return v, err
+	case 'S':
+		var v int16
+		err = binary.Read(j.r, binary.BigEndian, &v)
+		return v, err
+	case 'Z':
+		var v bool
+		err = binary.Read(j.r, binary.BigEndian, &v)
+		return v, err
+	case 'L', '[':
+		return j.readContent()
+	default:
+		return nil, fmt.Errorf("%w, unknown field type %q", JavaSerializationError, typeCode)
+	}
+}
+
+// reads a string in Java's modified UTF-8, which is the same as UTF-8 for everything a map name should contain
+func (j *javaReader) readUTF(long bool) (string, error) {
+	var n uint64
+	if long {
+		if err := binary.Read(j.r, binary.BigEndian, &n); err != nil {
+			return "", err
+		}
+	} else {
+		var n16 uint16
+		if err := binary.Read(j.r, binary.BigEndian, &n16); err != nil {
+			return "", err
+		}
+		n = uint64(n16)
+	}
+	if n > 1<<20 {
+		return "", fmt.Errorf("%w, string of %v bytes", JavaSerializationError, n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(j.r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
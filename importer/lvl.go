@@ -0,0 +1,57 @@
+package importer
+
+import (
+	"encoding/binary"
+	"io"
+
+	"marmalade/classicworld"
+)
+
+// the first value of .lvl files since MCSharp 5, older files start with the width instead
+const lvlMagic = 1874
+
+// ReadLvl reads an MCSharp level, which MCLawl, MCForge and MCGalaxy also use
+// They are gzip compressed, little endian, and have a header of
+//
+//	[magic 1874] width length height spawnX spawnZ spawnY (all uint16) spawnYaw spawnPitch (bytes) [visit and build permissions (bytes)]
+//
+// followed by the blocks in the same order as ClassicWorld, where the bracketed fields are only in files that start with the magic
+// Anything after the blocks, such as the custom blocks of MCGalaxy, is ignored
+func ReadLvl(r io.Reader) (*classicworld.World, error) {
+	bufR, err := gunzip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var x uint16
+	if err := binary.Read(bufR, binary.LittleEndian, &x); err != nil {
+		return nil, err
+	}
+	hasMagic := x == lvlMagic
+	if hasMagic {
+		if err := binary.Read(bufR, binary.LittleEndian, &x); err != nil {
+			return nil, err
+		}
+	}
+	var header struct {
+		Z, Y                   uint16
+		SpawnX, SpawnZ, SpawnY uint16
+		Yaw, Pitch             uint8
+	}
+	if err := binary.Read(bufR, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if hasMagic {
+		var permissions [2]byte
+		if _, err := io.ReadFull(bufR, permissions[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := newWorld(int(x), int(header.Y), int(header.Z))
+	if err != nil {
+		return nil, err
+	}
+	w.Spawn = classicworld.Spawn{X: header.SpawnX, Y: header.SpawnY, Z: header.SpawnZ, H: header.Yaw, P: header.Pitch}
+	return w, readBlocks(bufR, w)
+}
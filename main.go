@@ -2,21 +2,116 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"marmalade/accounts"
+	"marmalade/auth"
 	"marmalade/commands"
 	"marmalade/config"
+	"marmalade/heartbeat"
+	"marmalade/helpers"
+	"marmalade/importer"
+	"marmalade/moderation"
+	"marmalade/packets"
 	"marmalade/packets/inbound"
 	"marmalade/packets/outbound"
+	"marmalade/ranks"
+	"marmalade/throttle"
 	"marmalade/world"
 )
 
+var (
+	// salt used for the heartbeat and name verification, randomly generated on every start
+	salt = helpers.NewSalt(16)
+	// networks that skip name verification
+	verifyExempt []*net.IPNet
+	// what to do with inbound packets that are disabled or not handled
+	packetPolicy packets.Policy
+	// connection limits
+	limiter = throttle.NewLimiter(config.MaxConnsPerIP, config.JoinsPerIP, config.JoinWindow, config.JoinCooldown, config.MaxPendingHandshakes)
+)
+
+func heartbeatInfo() heartbeat.Info {
+	_, portStr, _ := net.SplitHostPort(config.Address)
+	port, _ := strconv.Atoi(portStr)
+	return heartbeat.Info{
+		Name:     config.ServerName,
+		Port:     port,
+		Users:    world.PlayerCount(),
+		MaxUsers: config.MaxPlayers,
+		Public:   config.Public,
+		Salt:     salt,
+		Software: "marmalade",
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		importMaps(os.Args[2:])
+		return
+	}
+
+	// Apply packet policy
+	policy, policyErr := packets.ParsePolicy(config.PacketPolicy)
+	if policyErr != nil {
+		panic(fmt.Sprintf("FATAL: %v", policyErr))
+	}
+	packetPolicy = policy
+	for _, v := range config.DisabledPackets {
+		id, idErr := strconv.ParseUint(v, 0, 8)
+		if idErr != nil {
+			panic(fmt.Sprintf("FATAL: Invalid disabled packet id `%v`: %v", v, idErr))
+		}
+		inbound.Registry.SetDisabled(byte(id), true)
+	}
+	// Parse name verification exemptions
+	exempt, exemptErr := auth.ParseNetworks(config.VerifyExempt)
+	if exemptErr != nil {
+		panic(fmt.Sprintf("FATAL: Invalid name verification exemption: %v", exemptErr))
+	}
+	verifyExempt = exempt
+	if config.VerifyNames && config.HeartbeatURL == "" {
+		panic("FATAL: Name verification needs a heartbeat URL, the server list only verifies names of servers that announce themselves")
+	}
+	if config.DuplicateLogin != "kick-old" && config.DuplicateLogin != "reject-new" {
+		panic(fmt.Sprintf("FATAL: Unknown duplicate login behaviour `%v`", config.DuplicateLogin))
+	}
+	if !world.ValidPhysicsMode(config.Physics) {
+		panic(fmt.Sprintf("FATAL: Unknown physics mode `%v`", config.Physics))
+	}
+	for _, v := range config.BlastImmune {
+		id, idErr := strconv.ParseUint(v, 0, 8)
+		if idErr != nil {
+			panic(fmt.Sprintf("FATAL: Invalid blast immune block id `%v`: %v", v, idErr))
+		}
+		world.SetBlastImmune(byte(id))
+	}
+	for _, v := range config.ForbiddenBlocks {
+		id, idErr := strconv.ParseUint(v, 0, 8)
+		if idErr != nil {
+			panic(fmt.Sprintf("FATAL: Invalid forbidden block id `%v`: %v", v, idErr))
+		}
+		world.SetForbiddenBlock(byte(id))
+	}
 	// Initialize world
 	world.Initialize()
+	// Load bans
+	moderation.Initialize()
+	// Load ranks
+	ranks.Initialize()
+	// Load accounts
+	accounts.Initialize()
+	// Announce the server
+	if config.HeartbeatURL != "" {
+		go heartbeat.New(config.HeartbeatURL, config.HeartbeatInterval, config.HeartbeatMaxBackoff, heartbeatInfo).Run()
+	}
 	// Create new listener
 	listener, listenerErr := net.Listen("tcp", config.Address)
 	if listenerErr != nil {
@@ -53,18 +148,84 @@ func handleConnection(conn net.Conn) {
 	log.Printf("INFO: %v has established a connection.", conn.RemoteAddr().String())
 
 	reader := bufio.NewReader(conn)
+	writer := outbound.NewAFCBW(conn, config.BufferFlushInterval)
+	defer writer.Close()
+
+	release, throttleErr := limiter.Accept(auth.AddrIP(conn.RemoteAddr()).String())
+	if throttleErr != nil {
+		log.Printf("INFO: Refused connection from %v: %v", conn.RemoteAddr().String(), throttleErr)
+		if errors.Is(throttleErr, throttle.JoinRateError) {
+			world.Disconnect(conn, writer, world.ReasonJoinThrottled)
+		} else {
+			world.Disconnect(conn, writer, world.ReasonTooManyConnections)
+		}
+		return
+	}
+	defer release()
 
-	_ /* protocol version */, username, _ /* verification key */, readPlayerIdentificationErr := inbound.ReadPlayerIdentification(reader)
+	endHandshake, handshakeErr := limiter.BeginHandshake()
+	if handshakeErr != nil {
+		log.Printf("INFO: Refused connection from %v: %v", conn.RemoteAddr().String(), handshakeErr)
+		world.Disconnect(conn, writer, world.ReasonServerBusy)
+		return
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(config.HandshakeTimeout)); err != nil {
+		log.Printf("ERROR: Failed to set handshake timeout for %v: %v", conn.RemoteAddr().String(), err)
+		endHandshake()
+		return
+	}
+	protocolVersion, username, verificationKey, readPlayerIdentificationErr := inbound.ReadPlayerIdentification(reader)
+	endHandshake()
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		log.Printf("ERROR: Failed to clear handshake timeout for %v: %v", conn.RemoteAddr().String(), err)
+		return
+	}
 	if readPlayerIdentificationErr != nil {
 		log.Printf("ERROR: Error reading player identification packet from %v, error: %v", conn.RemoteAddr().String(), readPlayerIdentificationErr)
+		world.Disconnect(conn, writer, world.ReasonBadIdentification)
 		return
 	}
-	log.Printf("INFO: Received a player identification packet from %v, they say their username is `%v`", conn.RemoteAddr().String(), username)
+	log.Printf("INFO: Received a player identification packet from %v, they say their username is `%v` and use protocol version %v", conn.RemoteAddr().String(), username, protocolVersion)
 
-	writer := outbound.NewAFCBW(conn, config.BufferFlushInterval)
-	defer writer.Close()
+	if ban, banned := moderation.Bans.CheckName(username); banned {
+		world.Disconnect(conn, writer, world.Banned(ban.Message()))
+		return
+	}
+	if ban, banned := moderation.Bans.CheckIP(auth.AddrIP(conn.RemoteAddr())); banned {
+		world.Disconnect(conn, writer, world.Banned(ban.Message()))
+		return
+	}
+
+	if !moderation.Whitelist.Allowed(username) {
+		world.Disconnect(conn, writer, world.ReasonNotWhitelisted)
+		return
+	}
+
+	if !auth.ValidUsername(username) {
+		world.Disconnect(conn, writer, world.ReasonInvalidUsername)
+		return
+	}
+
+	if !packets.SupportedProtocol(protocolVersion) {
+		world.Disconnect(conn, writer, world.ReasonUnsupportedProtocol(protocolVersion))
+		return
+	}
+	writer.SetProtocolVersion(protocolVersion)
 
-	sendServerIdentificationErr := writer.SendServerIdentification(config.ServerName, config.ServerMOTD, false)
+	verified := auth.Verify(salt, username, verificationKey)
+	if config.VerifyNames && !verified && !auth.InNetworks(auth.AddrIP(conn.RemoteAddr()), verifyExempt) {
+		log.Printf("INFO: %v failed name verification as `%v`", conn.RemoteAddr().String(), username)
+		world.Disconnect(conn, writer, world.ReasonNotVerified)
+		return
+	}
+
+	// a player who still has to log in could be anyone, so they only get the default rank until they do, see SetAuthenticated
+	authenticated := !config.Accounts || verified
+	rank := ranks.Of(username)
+	if !authenticated {
+		rank = ranks.Default()
+	}
+	sendServerIdentificationErr := writer.SendServerIdentification(config.ServerName, config.ServerMOTD, rank.OP)
 	if sendServerIdentificationErr != nil {
 		log.Printf("ERROR: Error sending server identification packet to %v, error: %v", conn.RemoteAddr().String(), sendServerIdentificationErr)
 		return
@@ -72,29 +233,56 @@ func handleConnection(conn net.Conn) {
 
 	p := &world.Player{
 		Username: username,
-		OP:       false,
+		Protocol: protocolVersion,
+		Rank:     rank,
+		Position: world.Main.SpawnPos,
+		World:    world.Main,
+		Conn:     conn,
 		Writer:   writer,
+
+		Authenticated: authenticated,
+	}
+	if old := world.FindConnected(username); old != nil {
+		// a session that hasn't proven anything yet never replaces one that logged in
+		if config.DuplicateLogin == "reject-new" || (!p.Authenticated && old.IsAuthenticated()) {
+			log.Printf("INFO: Refused `%v` from %v, they are already online", username, conn.RemoteAddr().String())
+			world.Disconnect(conn, writer, world.ReasonAlreadyOnline)
+			return
+		}
+		log.Printf("INFO: `%v` logged in again from %v, replacing the old session", username, conn.RemoteAddr().String())
+		old.Kick(world.ReasonLoggedInElsewhere)
+		world.RemovePlayer(old)
+	}
+	addErr := world.AddPlayer(p)
+	if errors.Is(addErr, world.ServerFullError) && config.JoinQueue {
+		addErr = waitInQueue(p, reader)
 	}
-	if !world.AddPlayer(p) {
-		log.Printf("ERROR: Max players reached!")
+	if addErr != nil {
+		log.Printf("ERROR: Failed to add player `%v`: %v", username, addErr)
+		if errors.Is(addErr, world.DuplicateUsernameError) {
+			world.Disconnect(conn, writer, world.ReasonAlreadyOnline)
+		} else if !errors.Is(addErr, errQueueLeft) { // nobody is left to tell if they left the queue
+			world.Disconnect(conn, writer, world.ReasonServerFull)
+		}
 		return
 	}
-	defer world.RemovePlayer(p.ID)
+	defer world.RemovePlayer(p)
 	log.Printf("INFO: Assigned `%v` player id %v", username, p.ID)
 
-	if err := world.SendWorld(writer); err != nil {
+	if err := p.World.SendTo(writer); err != nil {
 		log.Printf("ERROR: Failed to send world: %v", err)
+		p.Kick(world.ReasonMapSendFailed)
 		return
 	}
 
 	if err := writer.SendSpawnPlayer(
 		255,
 		username,
-		world.SpawnPos.X,
-		world.SpawnPos.Y,
-		world.SpawnPos.Z,
-		world.SpawnPos.Yaw,
-		world.SpawnPos.Pitch,
+		p.X,
+		p.Y,
+		p.Z,
+		p.Yaw,
+		p.Pitch,
 	); err != nil {
 		log.Printf("ERROR: Failed to send spawn player: %v", err)
 		return
@@ -111,48 +299,130 @@ func handleConnection(conn net.Conn) {
 		return
 	}
 
+	if !p.Authenticated {
+		if accounts.Registered(p.Username) {
+			_ = writer.SendMessageStr("[System] Please log in with /login <password>")
+		} else {
+			_ = writer.SendMessageStr("[System] Please register with /register <password>")
+		}
+		loginTimer := time.AfterFunc(config.LoginTimeout, func() {
+			if !p.IsAuthenticated() {
+				p.Kick(world.ReasonLoginTimeout)
+			}
+		})
+		defer loginTimer.Stop()
+	}
+
 	world.BroadcastMessage(fmt.Sprintf("[System] Joined: %v", p.Username))
 	defer world.BroadcastMessage(fmt.Sprintf("[System] Left: %v", p.Username))
 
+	dispatcher := packets.NewDispatcher(inbound.Registry, packetPolicy)
+	dispatcher.Handle(inbound.SetBlock{}, func(packet interface{}) error {
+		sb := packet.(*inbound.SetBlock)
+		world.HandleSetBlock(p, sb.X, sb.Y, sb.Z, sb.Mode, sb.BlockType)
+		return nil
+	})
+	dispatcher.Handle(inbound.PositionAndOrientation{}, func(packet interface{}) error {
+		po := packet.(*inbound.PositionAndOrientation)
+		world.HandlePositionAndOrientation(p, po.X, po.Y, po.Z, po.Yaw, po.Pitch)
+		return nil
+	})
+	dispatcher.Handle(inbound.Message{}, func(packet interface{}) error {
+		message := packet.(*inbound.Message).Message
+		if strings.HasPrefix(message, config.CommandPrefix) {
+			commands.HandleCommand(p, message[len(config.CommandPrefix):])
+		} else if !p.IsAuthenticated() {
+			_ = writer.SendMessageStr("[System] You need to log in before you can chat.")
+		} else {
+			world.BroadcastMessage(fmt.Sprintf("<%v> %v", p.Username, message))
+		}
+		return nil
+	})
+
 	for {
-		b, bErr := reader.ReadByte()
-		if bErr != nil {
-			log.Printf("ERROR: Failed to read packet id: %v", bErr)
+		if err := dispatcher.Next(reader); err != nil {
+			log.Printf("ERROR: Failed to handle packet from %v: %v", p.Username, err)
+			if errors.Is(err, packets.UnknownPacketError) || errors.Is(err, packets.RejectedPacketError) {
+				p.Kick(world.ReasonInvalidPacket)
+			}
 			return
 		}
-		if err := reader.UnreadByte(); err != nil {
-			log.Printf("ERROR: Failed to unread packet id: %v", err)
-			return
+	}
+}
+
+var errQueueLeft = errors.New("left the join queue")
+
+// waitInQueue keeps the connection in the join queue until the player is added, showing them their position in the queue
+// The connection is read from while queued, so that the player leaves the queue as soon as they disconnect
+func waitInQueue(p *world.Player, reader *bufio.Reader) error {
+	ticket, ticketErr := world.Enqueue(p)
+	if ticketErr != nil {
+		return world.ServerFullError
+	}
+	log.Printf("INFO: `%v` is waiting in the join queue", p.Username)
+
+	// clients don't send anything until they got the world, so reading only returns once the connection is closed
+	// Peek leaves anything that was sent in the reader for the packet loop
+	gone, watched := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(watched)
+		if _, err := reader.Peek(1); err != nil {
+			close(gone)
 		}
-		switch b {
-		case 0x05: // set block
-			x, y, z, mode, blockType, packetErr := inbound.ReadSetBlock(reader)
-			if packetErr != nil {
-				log.Printf("ERROR: Failed to read set block packet: %v", packetErr)
-				return
-			}
-			world.HandleSetBlock(x, y, z, mode, blockType)
-		case 0x08: // position and orientation
-			x, y, z, yaw, pitch, packetErr := inbound.ReadPositionAndOrientation(reader)
-			if packetErr != nil {
-				log.Printf("ERROR: Failed to read position and orientation packet: %v", packetErr)
-				return
-			}
-			world.HandlePositionAndOrientation(p, x, y, z, yaw, pitch)
-		case 0x0d: // message
-			message, packetErr := inbound.ReadMessage(reader)
-			if packetErr != nil {
-				log.Printf("ERROR: Failed to read chat message: %v", packetErr)
-				return
-			}
-			if strings.HasPrefix(message, config.CommandPrefix) {
-				commands.HandleCommand(p, message[len(config.CommandPrefix):])
-			} else {
-				world.BroadcastMessage(fmt.Sprintf("<%v> %v", p.Username, message))
-			}
-		default:
-			log.Printf("ERROR: Invalid packet ID: %v", b)
-			return
+	}()
+	defer func() {
+		// interrupt the read, so that the packet loop can read from the connection
+		_ = p.Conn.SetReadDeadline(time.Now())
+		<-watched
+		_ = p.Conn.SetReadDeadline(time.Time{})
+	}()
+	leave := func() error {
+		if !ticket.Leave() {
+			world.RemovePlayer(p)
 		}
+		return errQueueLeft
+	}
+
+	ticker := time.NewTicker(config.QueueUpdateInterval)
+	defer ticker.Stop()
+	for {
+		// the loading screen shows the server name and motd, so resending the identification packet shows the position
+		motd := fmt.Sprintf("The server is full, you are #%v in the queue.", ticket.Position())
+		err := p.Writer.SendServerIdentification(config.ServerName, motd, p.Rank.OP)
+		if err == nil {
+			err = p.Writer.Flush()
+		}
+		if err != nil { // the client is gone
+			return leave()
+		}
+
+		select {
+		case err := <-ticket.Admitted():
+			return err
+		case <-gone:
+			log.Printf("INFO: `%v` left the join queue", p.Username)
+			return leave()
+		case <-ticker.C:
+		}
+	}
+}
+
+// importMaps converts maps of other servers into worlds, run with `marmalade import <file> [name]`
+func importMaps(args []string) {
+	if len(args) != 1 && len(args) != 2 {
+		fmt.Printf("Usage: %v import <file> [name]\nSupported formats: %v\n", os.Args[0], strings.Join(importer.Extensions, ", "))
+		os.Exit(2)
+	}
+	name := ""
+	if len(args) == 2 {
+		name = args[1]
+	}
+	if err := os.MkdirAll(config.WorldsDir, 0755); err != nil {
+		log.Fatalf("ERROR: Failed to create %v: %v", config.WorldsDir, err)
+	}
+	w, err := world.ImportWorld(args[0], name)
+	if err != nil {
+		log.Fatalf("ERROR: Failed to import %v: %v", args[0], err)
 	}
+	log.Printf("INFO: Imported %v as world %v (%vx%vx%v)", args[0], w.Name, w.XSize, w.YSize, w.ZSize)
 }
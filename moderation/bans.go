@@ -0,0 +1,136 @@
+package moderation
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"marmalade/auth"
+	"marmalade/helpers"
+)
+
+type Ban struct {
+	Target  string    // username, IP or CIDR
+	Reason  string    `json:",omitempty"`
+	By      string    // username of whoever made the ban
+	Created time.Time // when the ban was made
+	Expires time.Time `json:",omitempty"` // zero for permanent bans
+}
+
+// Expired reports whether a temporary ban has run out
+func (b Ban) Expired(now time.Time) bool {
+	return !b.Expires.IsZero() && now.After(b.Expires)
+}
+
+// Message describes the ban to the banned player
+func (b Ban) Message() string {
+	msg := "Banned"
+	if b.Reason != "" {
+		msg += ": " + b.Reason
+	}
+	if !b.Expires.IsZero() {
+		msg += fmt.Sprintf(" (for %v)", time.Until(b.Expires).Round(time.Minute))
+	}
+	return msg
+}
+
+// BanList holds name and IP bans, saving itself to disk on every change
+type BanList struct {
+	path  string
+	mu    *sync.Mutex
+	Names map[string]Ban // keyed by lowercase username
+	IPs   map[string]Ban // keyed by CIDR
+}
+
+// LoadBans loads the ban list at path, starting with an empty one if it doesn't exist
+func LoadBans(path string) (*BanList, error) {
+	l := &BanList{
+		path:  path,
+		mu:    new(sync.Mutex),
+		Names: map[string]Ban{},
+		IPs:   map[string]Ban{},
+	}
+	if err := helpers.LoadJSON(path, l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// must be called with mu held
+func (l *BanList) save() error {
+	return helpers.SaveJSON(l.path, l)
+}
+
+// BanName bans a username, replacing any existing ban on it
+func (l *BanList) BanName(ban Ban) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Names[strings.ToLower(ban.Target)] = ban
+	return l.save()
+}
+
+// BanIP bans an IP or CIDR, replacing any existing ban on it
+func (l *BanList) BanIP(ban Ban) error {
+	network, err := auth.ParseNetwork(ban.Target)
+	if err != nil {
+		return err
+	}
+	ban.Target = network.String()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.IPs[ban.Target] = ban
+	return l.save()
+}
+
+// Unban removes the ban on a username, IP or CIDR, and reports whether there was one
+func (l *BanList) Unban(target string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := strings.ToLower(target)
+	_, found := l.Names[key]
+	delete(l.Names, key)
+	if network, err := auth.ParseNetwork(target); err == nil {
+		if _, ok := l.IPs[network.String()]; ok {
+			found = true
+			delete(l.IPs, network.String())
+		}
+	}
+	if !found {
+		return false, nil
+	}
+	return true, l.save()
+}
+
+// CheckName returns the active ban on the username, if any
+func (l *BanList) CheckName(username string) (Ban, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ban, found := l.Names[strings.ToLower(username)]
+	if !found || ban.Expired(time.Now()) {
+		return Ban{}, false
+	}
+	return ban, true
+}
+
+// CheckIP returns an active ban covering the IP, if any
+func (l *BanList) CheckIP(ip net.IP) (Ban, bool) {
+	if ip == nil {
+		return Ban{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for _, v := range l.IPs {
+		if v.Expired(now) {
+			continue
+		}
+		if network, err := auth.ParseNetwork(v.Target); err == nil && network.Contains(ip) {
+			return v, true
+		}
+	}
+	return Ban{}, false
+}
@@ -0,0 +1,84 @@
+package moderation
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBanIPNetworks(t *testing.T) {
+	l, err := LoadBans(filepath.Join(t.TempDir(), "bans.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.BanIP(Ban{Target: "10.1.0.0/16"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.BanIP(Ban{Target: "192.168.0.7"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.BanIP(Ban{Target: "not an ip"}); err == nil {
+		t.Fatal("banned an invalid IP")
+	}
+
+	for ip, banned := range map[string]bool{
+		"10.1.0.1": true, "10.1.255.255": true, "10.2.0.1": false,
+		"192.168.0.7": true, "192.168.0.8": false, "::1": false,
+	} {
+		if _, got := l.CheckIP(net.ParseIP(ip)); got != banned {
+			t.Errorf("%v: expected banned %v, got %v", ip, banned, got)
+		}
+	}
+}
+
+func TestBansExpire(t *testing.T) {
+	l, err := LoadBans(filepath.Join(t.TempDir(), "bans.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	_ = l.BanName(Ban{Target: "expired", Created: now.Add(-2 * time.Hour), Expires: now.Add(-time.Hour)})
+	_ = l.BanName(Ban{Target: "Temporary", Created: now, Expires: now.Add(time.Hour)})
+	_ = l.BanIP(Ban{Target: "1.2.3.4", Created: now.Add(-2 * time.Hour), Expires: now.Add(-time.Hour)})
+
+	if _, banned := l.CheckName("expired"); banned {
+		t.Error("an expired name ban is still active")
+	}
+	if _, banned := l.CheckName("temporary"); !banned {
+		t.Error("a temporary name ban that hasn't run out isn't active")
+	}
+	if _, banned := l.CheckIP(net.ParseIP("1.2.3.4")); banned {
+		t.Error("an expired IP ban is still active")
+	}
+}
+
+func TestBansReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	l, err := LoadBans(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires := time.Unix(time.Now().Add(time.Hour).Unix(), 0)
+	_ = l.BanName(Ban{Target: "Griefer", Reason: "griefing", By: "admin", Expires: expires})
+	_ = l.BanName(Ban{Target: "forgiven"})
+	_ = l.BanIP(Ban{Target: "10.0.0.0/8"})
+	if found, err := l.Unban("FORGIVEN"); !found || err != nil {
+		t.Fatalf("failed to unban: %v %v", found, err)
+	}
+
+	reloaded, err := LoadBans(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ban, banned := reloaded.CheckName("griefer")
+	if !banned || ban.Reason != "griefing" || ban.By != "admin" || !ban.Expires.Equal(expires) {
+		t.Fatalf("the name ban wasn't reloaded: %+v", ban)
+	}
+	if _, banned := reloaded.CheckName("forgiven"); banned {
+		t.Fatal("a removed ban came back")
+	}
+	if _, banned := reloaded.CheckIP(net.ParseIP("10.20.30.40")); !banned {
+		t.Fatal("the IP ban wasn't reloaded")
+	}
+}
@@ -0,0 +1,23 @@
+package moderation
+
+import "marmalade/config"
+
+var (
+	Bans      *BanList
+	Whitelist *AllowList
+)
+
+// Initialize loads the moderation lists from disk
+func Initialize() {
+	bans, bansErr := LoadBans(config.BansPath)
+	if bansErr != nil {
+		panic(bansErr)
+	}
+	Bans = bans
+
+	whitelist, whitelistErr := LoadAllowList(config.WhitelistPath)
+	if whitelistErr != nil {
+		panic(whitelistErr)
+	}
+	Whitelist = whitelist
+}
@@ -0,0 +1,83 @@
+package moderation
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"marmalade/helpers"
+)
+
+// AllowList restricts who can join while it is enabled, saving itself to disk on every change
+type AllowList struct {
+	path    string
+	mu      *sync.Mutex
+	Enabled bool
+	Names   map[string]string // lowercase username to username as it was added
+}
+
+// LoadAllowList loads the whitelist at path, starting with an empty, disabled one if it doesn't exist
+func LoadAllowList(path string) (*AllowList, error) {
+	w := &AllowList{path: path, mu: new(sync.Mutex), Names: map[string]string{}}
+	if err := helpers.LoadJSON(path, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// must be called with mu held
+func (w *AllowList) save() error {
+	return helpers.SaveJSON(w.path, w)
+}
+
+// Allowed reports whether the username may join, which is always true while the whitelist is disabled
+func (w *AllowList) Allowed(username string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, found := w.Names[strings.ToLower(username)]
+	return !w.Enabled || found
+}
+
+func (w *AllowList) SetEnabled(enabled bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.Enabled = enabled
+	return w.save()
+}
+
+func (w *AllowList) IsEnabled() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Enabled
+}
+
+func (w *AllowList) Add(username string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.Names[strings.ToLower(username)] = username
+	return w.save()
+}
+
+// Remove reports whether the username was on the whitelist
+func (w *AllowList) Remove(username string) (bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key := strings.ToLower(username)
+	if _, found := w.Names[key]; !found {
+		return false, nil
+	}
+	delete(w.Names, key)
+	return true, w.save()
+}
+
+// List returns the whitelisted usernames in alphabetical order
+func (w *AllowList) List() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]string, 0, len(w.Names))
+	for _, v := range w.Names {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,243 @@
+package packets
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Length of every string on the wire, padded with spaces
+const StringLength = 64
+
+var (
+	PacketIDAssertionFailure = errors.New("failed to assert packet id")
+	UnknownPacketError       = errors.New("unknown packet id")
+	RejectedPacketError      = errors.New("packet rejected by policy")
+)
+
+type fieldKind int
+
+const (
+	kindByte fieldKind = iota
+	kindShort
+	kindString
+	kindBytes
+)
+
+type field struct {
+	index int
+	kind  fieldKind
+	size  int
+	blank bool // `_` fields are written as zero values and discarded when read
+}
+
+// Definition describes a fixed size packet and the struct type that holds its payload
+// Fields are encoded in declaration order:
+// uint8/int8 as a byte, uint16/int16 as a big endian short, string as a space padded 64 byte string and [N]byte as is
+type Definition struct {
+	ID   byte
+	Name string
+	Size int // payload size, not counting the packet id
+	Type reflect.Type
+
+	fields []field
+}
+
+// Registry maps packet IDs to their definitions
+// Several definitions may share an ID (for example, layouts for different protocol versions);
+// when decoding, the first one registered for an ID is used
+type Registry struct {
+	byID     [256]*Definition
+	byType   map[reflect.Type]*Definition
+	disabled [256]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{byType: map[reflect.Type]*Definition{}}
+}
+
+// Register adds a packet definition built from the prototype's struct type
+// Panics on an unsupported field type or if the type is already registered, as this is a programming error
+func (r *Registry) Register(id byte, name string, prototype interface{}) *Definition {
+	t := reflect.TypeOf(prototype)
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("packets: %v (%v) is not a struct", name, t))
+	}
+	if _, found := r.byType[t]; found {
+		panic(fmt.Sprintf("packets: %v (%v) registered twice", name, t))
+	}
+	def := &Definition{ID: id, Name: name, Type: t}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fd := field{index: i, blank: f.Name == "_"}
+		switch {
+		case f.Type.Kind() == reflect.Uint8 || f.Type.Kind() == reflect.Int8:
+			fd.kind, fd.size = kindByte, 1
+		case f.Type.Kind() == reflect.Uint16 || f.Type.Kind() == reflect.Int16:
+			fd.kind, fd.size = kindShort, 2
+		case f.Type.Kind() == reflect.String:
+			fd.kind, fd.size = kindString, StringLength
+		case f.Type.Kind() == reflect.Array && f.Type.Elem().Kind() == reflect.Uint8:
+			fd.kind, fd.size = kindBytes, f.Type.Len()
+		default:
+			panic(fmt.Sprintf("packets: %v has field %v of unsupported type %v", name, f.Name, f.Type))
+		}
+		def.fields = append(def.fields, fd)
+		def.Size += fd.size
+	}
+	if r.byID[id] == nil {
+		r.byID[id] = def
+	}
+	r.byType[t] = def
+	return def
+}
+
+// Lookup returns the definition used to decode the packet ID, or nil if there is none
+func (r *Registry) Lookup(id byte) *Definition {
+	return r.byID[id]
+}
+
+// SetDisabled marks a packet ID as disabled, disabled packets are handled by the dispatcher's policy
+// Not safe to call concurrently with decoding
+func (r *Registry) SetDisabled(id byte, disabled bool) {
+	r.disabled[id] = disabled
+}
+
+func (r *Registry) Disabled(id byte) bool {
+	return r.disabled[id]
+}
+
+func (r *Registry) definitionOf(packet interface{}) (*Definition, reflect.Value) {
+	v := reflect.Indirect(reflect.ValueOf(packet))
+	def, found := r.byType[v.Type()]
+	if !found {
+		panic(fmt.Sprintf("packets: unregistered packet type %v", v.Type()))
+	}
+	return def, v
+}
+
+// Encode writes the packet ID followed by the packet's fields
+func (r *Registry) Encode(writer *bufio.Writer, packet interface{}) error {
+	def, v := r.definitionOf(packet)
+	if err := writer.WriteByte(def.ID); err != nil {
+		return err
+	}
+	for _, f := range def.fields {
+		fv := v.Field(f.index)
+		var err error
+		switch f.kind {
+		case kindByte:
+			if fv.Kind() == reflect.Int8 {
+				err = writer.WriteByte(byte(fv.Int()))
+			} else {
+				err = writer.WriteByte(byte(fv.Uint()))
+			}
+		case kindShort:
+			buf := [2]byte{}
+			if fv.Kind() == reflect.Int16 {
+				binary.BigEndian.PutUint16(buf[:], uint16(fv.Int()))
+			} else {
+				binary.BigEndian.PutUint16(buf[:], uint16(fv.Uint()))
+			}
+			_, err = writer.Write(buf[:])
+		case kindString:
+			_, err = writer.WriteString(ClassicString(fv.String()))
+		case kindBytes:
+			buf := make([]byte, f.size)
+			if !f.blank {
+				reflect.Copy(reflect.ValueOf(buf), fv)
+			}
+			_, err = writer.Write(buf)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode reads a whole packet and returns a pointer to a new struct holding it
+// Unknown IDs always fail with UnknownPacketError, because the length of their payload can't be known
+func (r *Registry) Decode(reader *bufio.Reader) (interface{}, *Definition, error) {
+	id, idErr := reader.ReadByte()
+	if idErr != nil {
+		return nil, nil, idErr
+	}
+	def := r.byID[id]
+	if def == nil {
+		return nil, nil, fmt.Errorf("%w: %v", UnknownPacketError, id)
+	}
+	v := reflect.New(def.Type)
+	return v.Interface(), def, decodeFields(reader, def, v.Elem())
+}
+
+// DecodeInto reads a packet into the struct pointed to by packet, failing if the ID doesn't match its type
+func (r *Registry) DecodeInto(reader *bufio.Reader, packet interface{}) error {
+	def, v := r.definitionOf(packet)
+	id, idErr := reader.ReadByte()
+	if idErr != nil {
+		return idErr
+	}
+	if id != def.ID {
+		return fmt.Errorf("%w, expected packet id %v, but instead got %v", PacketIDAssertionFailure, def.ID, id)
+	}
+	return decodeFields(reader, def, v)
+}
+
+func decodeFields(reader *bufio.Reader, def *Definition, v reflect.Value) error {
+	for _, f := range def.fields {
+		if f.blank {
+			if _, err := reader.Discard(f.size); err != nil {
+				return err
+			}
+			continue
+		}
+		fv := v.Field(f.index)
+		switch f.kind {
+		case kindByte:
+			b, err := reader.ReadByte()
+			if err != nil {
+				return err
+			}
+			if fv.Kind() == reflect.Int8 {
+				fv.SetInt(int64(int8(b)))
+			} else {
+				fv.SetUint(uint64(b))
+			}
+		case kindShort:
+			buf := [2]byte{}
+			if _, err := io.ReadFull(reader, buf[:]); err != nil {
+				return err
+			}
+			s := binary.BigEndian.Uint16(buf[:])
+			if fv.Kind() == reflect.Int16 {
+				fv.SetInt(int64(int16(s)))
+			} else {
+				fv.SetUint(uint64(s))
+			}
+		case kindString:
+			read, err := ReadN(reader, StringLength)
+			if err != nil {
+				return err
+			}
+			fv.SetString(strings.TrimSpace(string(read)))
+		case kindBytes:
+			if _, err := io.ReadFull(reader, fv.Slice(0, f.size).Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pads a string with spaces and trims it a length of 64
+func ClassicString(s string) string {
+	if len(s) >= StringLength {
+		return s[:StringLength]
+	}
+	return s + strings.Repeat("\x20", StringLength-len(s))
+}
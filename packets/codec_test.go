@@ -0,0 +1,63 @@
+package packets
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type testPacket struct {
+	A    uint8
+	_    byte
+	B    int16
+	Name string
+	Data [4]byte
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	r := NewRegistry()
+	def := r.Register(0x42, "test", testPacket{})
+	if def.Size != 1+1+2+StringLength+4 {
+		t.Fatalf("wrong size %v", def.Size)
+	}
+
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	in := &testPacket{A: 7, B: -2, Name: "hello", Data: [4]byte{1, 2, 3, 4}}
+	if err := r.Encode(w, in); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Flush()
+	if buf.Len() != 1+def.Size {
+		t.Fatalf("encoded %v bytes, expected %v", buf.Len(), 1+def.Size)
+	}
+
+	out, outDef, err := r.Decode(bufio.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outDef != def || *out.(*testPacket) != *in {
+		t.Fatalf("got %+v, expected %+v", out, in)
+	}
+}
+
+func TestDispatcherPolicy(t *testing.T) {
+	r := NewRegistry()
+	r.Register(0x01, "test", testPacket{})
+	packet := append([]byte{0x01}, make([]byte, r.Lookup(0x01).Size)...)
+
+	d := NewDispatcher(r, PolicyReject)
+	if err := d.Next(bufio.NewReader(bytes.NewReader(packet))); !errors.Is(err, RejectedPacketError) {
+		t.Fatalf("expected rejection, got %v", err)
+	}
+
+	d.Policy = PolicySkip
+	reader := bufio.NewReader(bytes.NewReader(append(packet, 0x02)))
+	if err := d.Next(reader); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Next(reader); !errors.Is(err, UnknownPacketError) {
+		t.Fatalf("expected unknown packet, got %v", err)
+	}
+}
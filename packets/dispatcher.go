@@ -0,0 +1,75 @@
+package packets
+
+import (
+	"bufio"
+	"fmt"
+	"reflect"
+)
+
+// Policy decides what happens to packets that are disabled or have no handler
+type Policy int
+
+const (
+	PolicyReject Policy = iota // fail with RejectedPacketError
+	PolicySkip                 // discard the packet and carry on
+)
+
+// ParsePolicy converts "reject" or "skip" into a Policy
+func ParsePolicy(s string) (Policy, error) {
+	switch s {
+	case "reject":
+		return PolicyReject, nil
+	case "skip":
+		return PolicySkip, nil
+	default:
+		return PolicyReject, fmt.Errorf("unknown packet policy `%v`", s)
+	}
+}
+
+// Handler receives a pointer to a decoded packet struct
+type Handler = func(packet interface{}) error
+
+// Dispatcher reads packets using a registry and passes them to the handler registered for their type
+type Dispatcher struct {
+	registry *Registry
+	handlers [256]Handler
+	Policy   Policy
+}
+
+func NewDispatcher(registry *Registry, policy Policy) *Dispatcher {
+	return &Dispatcher{registry: registry, Policy: policy}
+}
+
+// Handle registers the handler for packets of the prototype's type
+func (d *Dispatcher) Handle(prototype interface{}, handler Handler) {
+	def, found := d.registry.byType[reflect.TypeOf(prototype)]
+	if !found {
+		panic(fmt.Sprintf("packets: unregistered packet type %T", prototype))
+	}
+	d.handlers[def.ID] = handler
+}
+
+// Next reads one packet and dispatches it
+func (d *Dispatcher) Next(reader *bufio.Reader) error {
+	id, idErr := reader.Peek(1)
+	if idErr != nil {
+		return idErr
+	}
+	def := d.registry.Lookup(id[0])
+	if def == nil {
+		return fmt.Errorf("%w: %v", UnknownPacketError, id[0])
+	}
+	handler := d.handlers[def.ID]
+	if handler == nil || d.registry.Disabled(def.ID) {
+		if d.Policy == PolicySkip {
+			_, err := reader.Discard(1 + def.Size)
+			return err
+		}
+		return fmt.Errorf("%w: %v (%v)", RejectedPacketError, def.Name, def.ID)
+	}
+	packet, _, err := d.registry.Decode(reader)
+	if err != nil {
+		return err
+	}
+	return handler(packet)
+}
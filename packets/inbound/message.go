@@ -1,11 +1,10 @@
 package inbound
 
-import "bufio"
+type Message struct {
+	_       byte // (player id?), always 255
+	Message string
+}
 
-func ReadMessage(reader *bufio.Reader) (message string, err error) {
-	err = do(reader,
-		assertPacketID(0x0d),
-		discard(1), // (player id?), always 255
-		readString(&message))
-	return
+func init() {
+	Registry.Register(0x0d, "message", Message{})
 }
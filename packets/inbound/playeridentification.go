@@ -2,12 +2,19 @@ package inbound
 
 import "bufio"
 
+type PlayerIdentification struct {
+	ProtocolVersion uint8
+	Username        string
+	VerificationKey string
+	_               byte // unused
+}
+
+func init() {
+	Registry.Register(0x00, "player identification", PlayerIdentification{})
+}
+
 func ReadPlayerIdentification(reader *bufio.Reader) (protocolVersion uint8, username, verificationKey string, err error) {
-	err = do(reader,
-		assertPacketID(0x00),
-		readByte(&protocolVersion),
-		readString(&username),
-		readString(&verificationKey),
-		discard(1))
-	return
+	var packet PlayerIdentification
+	err = Registry.DecodeInto(reader, &packet)
+	return packet.ProtocolVersion, packet.Username, packet.VerificationKey, err
 }
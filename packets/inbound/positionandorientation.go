@@ -1,15 +1,11 @@
 package inbound
 
-import "bufio"
+type PositionAndOrientation struct {
+	_          byte // player id, always 255, referring to itself
+	X, Y, Z    uint16
+	Yaw, Pitch uint8
+}
 
-func ReadPositionAndOrientation(reader *bufio.Reader) (x, y, z uint16, yaw, pitch uint8, err error) {
-	err = do(reader,
-		assertPacketID(0x08),
-		discard(1), // player id, always 255, referring to itself
-		readShort(&x),
-		readShort(&y),
-		readShort(&z),
-		readByte(&yaw),
-		readByte(&pitch))
-	return
+func init() {
+	Registry.Register(0x08, "position and orientation", PositionAndOrientation{})
 }
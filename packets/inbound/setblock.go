@@ -1,14 +1,11 @@
 package inbound
 
-import "bufio"
+type SetBlock struct {
+	X, Y, Z   uint16
+	Mode      byte
+	BlockType byte
+}
 
-func ReadSetBlock(reader *bufio.Reader) (x, y, z uint16, mode, blockType byte, err error) {
-	err = do(reader,
-		assertPacketID(0x05),
-		readShort(&x),
-		readShort(&y),
-		readShort(&z),
-		readByte(&mode),
-		readByte(&blockType))
-	return
+func init() {
+	Registry.Register(0x05, "set block", SetBlock{})
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"marmalade/helpers"
+	"marmalade/packets"
 )
 
 // Auto Flushing Concurrent Buffered Writer
@@ -17,6 +18,7 @@ type AFCBW struct {
 	lock     *sync.Mutex
 	interval time.Duration
 	err      error
+	protocol uint8 // protocol version of the client, used to pick packet layouts
 }
 
 func NewAFCBW(writer io.Writer, interval time.Duration) *AFCBW {
@@ -24,6 +26,7 @@ func NewAFCBW(writer io.Writer, interval time.Duration) *AFCBW {
 	w.writer = bufio.NewWriter(writer)
 	w.lock = new(sync.Mutex)
 	w.interval = interval
+	w.protocol = packets.CurrentProtocolVersion
 	go w.autoFlush()
 	return w
 }
@@ -34,6 +37,34 @@ func (w *AFCBW) Close() {
 	w.err = errors.New("AFCBW: closed")
 }
 
+// SetProtocolVersion makes the writer use packet layouts understood by clients of the given protocol version
+func (w *AFCBW) SetProtocolVersion(version uint8) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.protocol = version
+}
+
+// ProtocolVersion returns the protocol version set with SetProtocolVersion
+func (w *AFCBW) ProtocolVersion() uint8 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.protocol
+}
+
+// Flush writes out any buffered data immediately instead of waiting for the next auto flush
+func (w *AFCBW) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.writer.Flush(); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
 func (w *AFCBW) do(actions ...helpers.Action) error {
 	w.lock.Lock()
 	defer w.lock.Unlock()
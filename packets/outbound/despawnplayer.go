@@ -1,6 +1,13 @@
 package outbound
 
+type DespawnPlayer struct {
+	PlayerID uint8
+}
+
+func init() {
+	Registry.Register(0x0c, "despawn player", DespawnPlayer{})
+}
+
 func (w *AFCBW) SendDespawnPlayer(playerID uint8) error {
-	return w.do(writeByte(0x0c),
-		writeByte(playerID))
+	return w.do(encode(&DespawnPlayer{playerID}))
 }
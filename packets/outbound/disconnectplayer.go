@@ -1,6 +1,13 @@
 package outbound
 
+type DisconnectPlayer struct {
+	Reason string
+}
+
+func init() {
+	Registry.Register(0x0e, "disconnect player", DisconnectPlayer{})
+}
+
 func (w *AFCBW) SendDisconnectPlayer(reason string) error {
-	return w.do(writeByte(0x0e),
-		writeString(reason))
+	return w.do(encode(&DisconnectPlayer{reason}))
 }
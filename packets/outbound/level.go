@@ -1,20 +1,35 @@
 package outbound
 
+type (
+	LevelInitialize struct{}
+
+	LevelDataChunk struct {
+		Length          uint16
+		Data            [1024]byte
+		PercentComplete uint8
+	}
+
+	LevelFinalize struct {
+		XSize, YSize, ZSize uint16
+	}
+)
+
+func init() {
+	Registry.Register(0x02, "level initialize", LevelInitialize{})
+	Registry.Register(0x03, "level data chunk", LevelDataChunk{})
+	Registry.Register(0x04, "level finalize", LevelFinalize{})
+}
+
 func (w *AFCBW) SendLevelInitialize() error {
-	return w.do(writeByte(0x02))
+	return w.do(encode(&LevelInitialize{}))
 }
 
-// destroys parameter data
 func (w *AFCBW) SendLevelDataChunk(length uint16, data []byte, percentComplete uint8) error {
-	return w.do(writeByte(0x03),
-		writeShort(length),
-		writeBytes(classicByteArray(data)),
-		writeByte(percentComplete))
+	packet := &LevelDataChunk{Length: length, PercentComplete: percentComplete}
+	copy(packet.Data[:], data[:length])
+	return w.do(encode(packet))
 }
 
 func (w *AFCBW) SendLevelFinalize(xSize, ySize, zSize uint16) error {
-	return w.do(writeByte(0x04),
-		writeShort(xSize),
-		writeShort(ySize),
-		writeShort(zSize))
+	return w.do(encode(&LevelFinalize{xSize, ySize, zSize}))
 }
@@ -1,13 +1,18 @@
 package outbound
 
+type Message struct {
+	PlayerID byte // unused byte? I'm not exactly sure what this is for, though it might represent player ID or message type
+	Message  string
+}
+
+func init() {
+	Registry.Register(0x0d, "message", Message{})
+}
+
 func (w *AFCBW) SendMessageStr(message string) error {
-	return w.do(writeByte(0x0d),
-		writeByte(0x00), // unused byte? I'm not exactly sure what this is for, though it might represent player ID or message type
-		writeString(message))
+	return w.do(encode(&Message{Message: message}))
 }
 
 func (w *AFCBW) SendMessageBytes(message []byte) error {
-	return w.do(writeByte(0x0d),
-		writeByte(0x00), // see above comment for reference
-		writeBytes(classicStrBytes(message)))
+	return w.do(encode(&Message{Message: string(message)}))
 }
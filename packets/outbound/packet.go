@@ -2,36 +2,23 @@ package outbound
 
 import (
 	"bufio"
-	"encoding/binary"
 
 	"marmalade/helpers"
+	"marmalade/packets"
 )
 
-func writeByte(b byte) helpers.Action {
-	return func(writer *bufio.Writer) error {
-		return writer.WriteByte(b)
-	}
-}
-
-func writeShort(s uint16) helpers.Action {
-	return func(writer *bufio.Writer) error {
-		buf := [2]byte{}
-		binary.BigEndian.PutUint16(buf[:], s)
-		_, err := writer.Write(buf[:])
-		return err
-	}
-}
+// Registry holds every packet the server can send to a client
+var Registry = packets.NewRegistry()
 
-func writeString(s string) helpers.Action {
+func encode(packet interface{}) helpers.Action {
 	return func(writer *bufio.Writer) error {
-		_, err := writer.WriteString(classicString(s))
-		return err
+		return Registry.Encode(writer, packet)
 	}
 }
 
-func writeBytes(b []byte) helpers.Action {
-	return func(writer *bufio.Writer) error {
-		_, err := writer.Write(b)
-		return err
+func userType(isOP bool) byte {
+	if isOP {
+		return 64
 	}
+	return 0
 }
@@ -1,11 +1,15 @@
 package outbound
 
+type PositionAndOrientation struct {
+	PlayerID   uint8
+	X, Y, Z    uint16
+	Yaw, Pitch uint8
+}
+
+func init() {
+	Registry.Register(0x08, "position and orientation", PositionAndOrientation{})
+}
+
 func (w *AFCBW) SendPositionAndOrientation(playerID uint8, x, y, z uint16, yaw, pitch uint8) error {
-	return w.do(writeByte(0x08),
-		writeByte(playerID),
-		writeShort(x),
-		writeShort(y),
-		writeShort(z),
-		writeByte(yaw),
-		writeByte(pitch))
+	return w.do(encode(&PositionAndOrientation{playerID, x, y, z, yaw, pitch}))
 }
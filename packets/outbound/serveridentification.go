@@ -1,9 +1,32 @@
 package outbound
 
+import "marmalade/packets"
+
+type (
+	ServerIdentification struct {
+		ProtocolVersion uint8
+		ServerName      string
+		ServerMOTD      string
+		UserType        byte
+	}
+
+	// Protocol versions before 7 don't have the user type byte
+	LegacyServerIdentification struct {
+		ProtocolVersion uint8
+		ServerName      string
+		ServerMOTD      string
+	}
+)
+
+func init() {
+	Registry.Register(0x00, "server identification", ServerIdentification{})
+	Registry.Register(0x00, "legacy server identification", LegacyServerIdentification{})
+}
+
 func (w *AFCBW) SendServerIdentification(serverName, serverMOTD string, isOP bool) error {
-	return w.do(writeByte(0x00), // packet id
-		writeByte(0x07), // protocol version
-		writeString(serverName),
-		writeString(serverMOTD),
-		writeByte(opByte(isOP)))
+	version := w.ProtocolVersion()
+	if !packets.SupportsUserType(version) {
+		return w.do(encode(&LegacyServerIdentification{version, serverName, serverMOTD}))
+	}
+	return w.do(encode(&ServerIdentification{version, serverName, serverMOTD, userType(isOP)}))
 }
@@ -1,9 +1,34 @@
 package outbound
 
+import (
+	"marmalade/helpers"
+	"marmalade/packets"
+)
+
+type SetBlock struct {
+	X, Y, Z   uint16
+	BlockType byte
+}
+
+func init() {
+	Registry.Register(0x06, "set block", SetBlock{})
+}
+
+// SendSetBlock tells the client that a block changed
+// Block types the client doesn't know are replaced with ones it does, see packets.FallbackBlock
 func (w *AFCBW) SendSetBlock(x, y, z uint16, blockType byte) error {
-	return w.do(writeByte(0x06),
-		writeShort(x),
-		writeShort(y),
-		writeShort(z),
-		writeByte(blockType))
+	return w.do(encode(&SetBlock{x, y, z, packets.FallbackBlock(w.ProtocolVersion(), blockType)}))
+}
+
+// SendSetBlocks sends many block changes at once, without other packets between them
+// Block types the client doesn't know are replaced like with SendSetBlock, changes itself is left as it is
+func (w *AFCBW) SendSetBlocks(changes []SetBlock) error {
+	version := w.ProtocolVersion()
+	actions := make([]helpers.Action, len(changes))
+	for i := range changes {
+		change := changes[i]
+		change.BlockType = packets.FallbackBlock(version, change.BlockType)
+		actions[i] = encode(&change)
+	}
+	return w.do(actions...)
 }
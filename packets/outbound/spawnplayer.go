@@ -1,12 +1,16 @@
 package outbound
 
+type SpawnPlayer struct {
+	PlayerID   uint8
+	PlayerName string
+	X, Y, Z    uint16
+	Yaw, Pitch uint8
+}
+
+func init() {
+	Registry.Register(0x07, "spawn player", SpawnPlayer{})
+}
+
 func (w *AFCBW) SendSpawnPlayer(playerID uint8, playerName string, x, y, z uint16, yaw, pitch uint8) error {
-	return w.do(writeByte(0x07),
-		writeByte(playerID),
-		writeString(playerName),
-		writeShort(x),
-		writeShort(y),
-		writeShort(z),
-		writeByte(yaw),
-		writeByte(pitch))
+	return w.do(encode(&SpawnPlayer{playerID, playerName, x, y, z, yaw, pitch}))
 }
@@ -0,0 +1,19 @@
+package outbound
+
+import "marmalade/packets"
+
+type UpdateUserType struct {
+	UserType byte
+}
+
+func init() {
+	Registry.Register(0x0f, "update user type", UpdateUserType{})
+}
+
+// Does nothing for clients that don't know about user types
+func (w *AFCBW) SendUpdateUserType(isOP bool) error {
+	if !packets.SupportsUserType(w.ProtocolVersion()) {
+		return nil
+	}
+	return w.do(encode(&UpdateUserType{userType(isOP)}))
+}
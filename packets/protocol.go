@@ -0,0 +1,77 @@
+package packets
+
+import "marmalade/blocks"
+
+// Classic protocol versions
+const (
+	ProtocolVersion5 uint8 = 5 // 0.0.19a
+	ProtocolVersion6 uint8 = 6 // 0.0.20a to 0.0.23a
+	ProtocolVersion7 uint8 = 7 // 0.28 to 0.30
+
+	CurrentProtocolVersion = ProtocolVersion7
+)
+
+// SupportedProtocol reports whether the server can talk to clients of the given protocol version
+func SupportedProtocol(version uint8) bool {
+	return version >= ProtocolVersion5 && version <= ProtocolVersion7
+}
+
+// SupportsUserType reports whether the protocol version has the user type byte in the server identification packet,
+// as well as the update user type (0x0f) packet
+func SupportsUserType(version uint8) bool {
+	return version >= ProtocolVersion7
+}
+
+// MaxBlock returns the highest block type that clients of the given protocol version know
+func MaxBlock(version uint8) byte {
+	switch {
+	case version >= ProtocolVersion7:
+		return blocks.Obsidian
+	case version == ProtocolVersion6:
+		return blocks.GoldBlock
+	default:
+		return blocks.Glass
+	}
+}
+
+// fallbacks[b] is an older block type that looks like b, for clients that don't know b
+// Following it repeatedly always ends at a block type every client knows
+var fallbacks = func() (f [256]byte) {
+	for i := range f {
+		f[i] = blocks.Stone // unknown block types
+	}
+	for b := blocks.Air; b <= blocks.Glass; b++ {
+		f[b] = b
+	}
+	for b := blocks.RedWool; b <= blocks.WhiteWool; b++ {
+		f[b] = blocks.Planks
+	}
+	f[blocks.Dandelion], f[blocks.Rose] = blocks.Sapling, blocks.Sapling
+	f[blocks.BrownMushroom], f[blocks.RedMushroom] = blocks.Sapling, blocks.Sapling
+	f[blocks.GoldBlock] = blocks.Sand
+	f[blocks.IronBlock] = blocks.Stone
+	f[blocks.DoubleSlab], f[blocks.Slab] = blocks.Stone, blocks.Stone
+	f[blocks.Bricks], f[blocks.TNT] = blocks.RedWool, blocks.RedWool
+	f[blocks.Bookshelf] = blocks.Planks
+	f[blocks.MossyCobblestone] = blocks.Cobblestone
+	f[blocks.Obsidian] = blocks.BlackWool
+	return f
+}()
+
+// FallbackBlock returns the block type clients of the given protocol version are sent instead of b
+// It is b itself if they know it, see MaxBlock, and otherwise a block type they know that looks like it
+func FallbackBlock(version uint8, b byte) byte {
+	for max := MaxBlock(version); b > max; {
+		b = fallbacks[b]
+	}
+	return b
+}
+
+// FallbackTable returns FallbackBlock of every block type for the protocol version, to convert many blocks at once
+func FallbackTable(version uint8) *[256]byte {
+	var t [256]byte
+	for i := range t {
+		t[i] = FallbackBlock(version, byte(i))
+	}
+	return &t
+}
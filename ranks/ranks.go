@@ -0,0 +1,201 @@
+package ranks
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"marmalade/config"
+	"marmalade/helpers"
+)
+
+// Rank is a named set of permission nodes
+//
+// Nodes are dot separated, such as `command.fill` or `place.20`
+// A node ending in `*` grants everything starting with what comes before it, and `*` alone grants everything
+// A node starting with `-` denies instead of granting
+// The rank's own nodes are checked in order before the nodes of the rank it inherits, and the first match wins
+type Rank struct {
+	Name        string
+	Level       int    // higher levels outrank lower ones
+	Inherits    string `json:",omitempty"` // name of the rank whose nodes are checked after this rank's own
+	OP          bool   `json:",omitempty"` // whether the client is told that the player is an operator
+	Permissions []string
+	parent      *Rank
+}
+
+// Has reports whether the rank (or a rank it inherits) grants the permission node
+func (r *Rank) Has(node string) bool {
+	for v := r; v != nil; v = v.parent {
+		for _, p := range v.Permissions {
+			deny := strings.HasPrefix(p, "-")
+			if matches(strings.TrimPrefix(p, "-"), node) {
+				return !deny
+			}
+		}
+	}
+	return false
+}
+
+func matches(pattern, node string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(node, pattern[:len(pattern)-1])
+	}
+	return pattern == node
+}
+
+type file struct {
+	Default string // rank of players that have none saved
+	Ranks   []*Rank
+}
+
+var (
+	UnknownRankError = errors.New("unknown rank")
+
+	mu       = new(sync.Mutex)
+	ranks    map[string]*Rank
+	defaultR *Rank
+	highest  *Rank
+	players  = map[string]string{} // lowercase username to rank name
+)
+
+// the ranks written to disk on first start
+var defaultFile = file{
+	Default: "builder",
+	Ranks: []*Rank{
+		{Name: "guest", Level: 0, Permissions: []string{"command.ping", "command.rank", "command.goto", "command.worlds", "command.main", "command.about"}},
+		{Name: "builder", Level: 10, Inherits: "guest", Permissions: []string{
+			"build", "delete",
+			"-place.7", "-place.8", "-place.9", "-place.10", "-place.11", // bedrock and liquids, before place.* so they match first
+			"place.*",
+			"command.tp", "command.undo", "command.zone",
+		}},
+		{Name: "mod", Level: 50, Inherits: "builder", Permissions: []string{
+			"slot.reserved",
+			"command.fill", "command.kick", "command.ban", "command.banip", "command.tempban", "command.unban", "command.whitelist",
+			"command.physics", "command.explode", "command.rollback",
+			"zone.create", "zone.manage",
+		}},
+		{Name: "admin", Level: 100, Inherits: "mod", OP: true, Permissions: []string{"*"}},
+	},
+}
+
+// Initialize loads the ranks and the ranks of players, writing the default ranks to disk if there are none
+func Initialize() {
+	f := file{}
+	if err := helpers.LoadJSON(config.RanksPath, &f); err != nil {
+		panic(err)
+	}
+	if len(f.Ranks) == 0 {
+		f = defaultFile
+		if err := helpers.SaveJSON(config.RanksPath, f); err != nil {
+			panic(err)
+		}
+	}
+	if err := load(f); err != nil {
+		panic(err)
+	}
+	if err := helpers.LoadJSON(config.PlayerRanksPath, &players); err != nil {
+		panic(err)
+	}
+}
+
+func load(f file) error {
+	byName := map[string]*Rank{}
+	for _, v := range f.Ranks {
+		byName[strings.ToLower(v.Name)] = v
+	}
+	// parents are only set once the file is known to be valid, as the ranks may be the ones in use
+	parents := map[*Rank]*Rank{}
+	for _, v := range f.Ranks {
+		if v.Inherits == "" {
+			continue
+		}
+		parent, found := byName[strings.ToLower(v.Inherits)]
+		if !found {
+			return fmt.Errorf("%w `%v` inherited by %v", UnknownRankError, v.Inherits, v.Name)
+		}
+		parents[v] = parent
+	}
+	// inheritance loops would make Has loop forever
+	for _, v := range f.Ranks {
+		seen := map[*Rank]bool{}
+		for r := v; r != nil; r = parents[r] {
+			if seen[r] {
+				return fmt.Errorf("rank %v inherits itself", v.Name)
+			}
+			seen[r] = true
+		}
+	}
+	def, found := byName[strings.ToLower(f.Default)]
+	if !found {
+		return fmt.Errorf("%w `%v` set as default", UnknownRankError, f.Default)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, v := range f.Ranks {
+		v.parent = parents[v]
+	}
+	ranks = byName
+	defaultR = def
+	highest = nil
+	for _, v := range f.Ranks {
+		if highest == nil || v.Level > highest.Level {
+			highest = v
+		}
+	}
+	return nil
+}
+
+// Get returns the rank with the name (case insensitive), or nil
+func Get(name string) *Rank {
+	mu.Lock()
+	defer mu.Unlock()
+	return ranks[strings.ToLower(name)]
+}
+
+// All returns every rank, lowest level first
+func All() []*Rank {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]*Rank, 0, len(ranks))
+	for _, v := range ranks {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Level < out[j].Level })
+	return out
+}
+
+// Of returns the saved rank of the username
+// Players without one get the highest rank if they are listed in config.Operators, and the default rank otherwise
+func Of(username string) *Rank {
+	mu.Lock()
+	defer mu.Unlock()
+	if r, found := ranks[strings.ToLower(players[strings.ToLower(username)])]; found {
+		return r
+	}
+	for _, v := range config.Operators {
+		if strings.EqualFold(v, username) {
+			return highest
+		}
+	}
+	return defaultR
+}
+
+// Default returns the rank of players without a saved one
+func Default() *Rank {
+	mu.Lock()
+	defer mu.Unlock()
+	return defaultR
+}
+
+// Set saves the rank of the username
+func Set(username string, rank *Rank) error {
+	mu.Lock()
+	defer mu.Unlock()
+	players[strings.ToLower(username)] = rank.Name
+	return helpers.SaveJSON(config.PlayerRanksPath, players)
+}
@@ -0,0 +1,74 @@
+package ranks
+
+import (
+	"testing"
+)
+
+func testRanks(t *testing.T) file {
+	f := file{
+		Default: "guest",
+		Ranks: []*Rank{
+			{Name: "guest", Level: 0, Permissions: []string{"command.ping", "place.*"}},
+			{Name: "builder", Level: 10, Inherits: "Guest", Permissions: []string{"-place.7", "-command.ping", "build"}},
+			{Name: "mod", Level: 50, Inherits: "builder", Permissions: []string{"command.*", "place.7"}},
+			{Name: "admin", Level: 100, Inherits: "mod", Permissions: []string{"*"}},
+		},
+	}
+	if err := load(f); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestHas(t *testing.T) {
+	testRanks(t)
+	for _, c := range []struct {
+		rank, node string
+		expected   bool
+	}{
+		{"guest", "command.ping", true},
+		{"guest", "command.pingpong", false},
+		{"guest", "place.1", true},
+		{"guest", "place", false}, // `place.*` only matches what starts with `place.`
+		{"guest", "build", false},
+		{"builder", "build", true},
+		{"builder", "place.1", true},       // inherited
+		{"builder", "place.7", false},      // own deny before the inherited grant
+		{"builder", "command.ping", false}, // denied, even though guest grants it
+		{"mod", "command.ping", true},      // own wildcard before the inherited deny
+		{"mod", "place.7", true},
+		{"mod", "place.8", true},
+		{"mod", "delete", false},
+		{"admin", "delete", true},
+		{"admin", "anything.at.all", true},
+	} {
+		if got := Get(c.rank).Has(c.node); got != c.expected {
+			t.Errorf("%v has %v: %v, expected %v", c.rank, c.node, got, c.expected)
+		}
+	}
+}
+
+func TestInheritanceCycle(t *testing.T) {
+	f := testRanks(t)
+	f.Ranks[0].Inherits = "admin"
+	if err := load(f); err == nil {
+		t.Fatal("loaded ranks that inherit each other")
+	}
+	// the rejected file shares its ranks with the loaded ones, which must not have been changed
+	if Get("guest").parent != nil || Get("guest").Has("build") {
+		t.Fatal("a rejected file changed the loaded ranks")
+	}
+}
+
+func TestUnknownRank(t *testing.T) {
+	f := testRanks(t)
+	f.Ranks[1].Inherits = "nobody"
+	if err := load(f); err == nil {
+		t.Fatal("loaded a rank inheriting an unknown rank")
+	}
+	f.Ranks[1].Inherits = "guest"
+	f.Default = "nobody"
+	if err := load(f); err == nil {
+		t.Fatal("loaded an unknown default rank")
+	}
+}
@@ -0,0 +1,133 @@
+package throttle
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	TooManyConnectionsError = errors.New("throttle: too many connections from this address")
+	JoinRateError           = errors.New("throttle: joining too often")
+	TooManyPendingError     = errors.New("throttle: too many pending handshakes")
+)
+
+// Limiter enforces per IP connection caps, a per IP join rate with a cooldown and a global cap on pending handshakes
+type Limiter struct {
+	MaxPerIP      int // concurrent connections per IP, 0 for no limit
+	JoinsPerIP    int // connections per IP allowed within JoinWindow, 0 for no limit
+	JoinWindow    time.Duration
+	JoinCooldown  time.Duration // how long an IP is refused for after going over JoinsPerIP
+	MaxPending    int           // handshakes in progress at once, 0 for no limit
+	now           func() time.Time
+	mu            *sync.Mutex
+	active        map[string]int
+	joins         map[string][]time.Time
+	cooldownUntil map[string]time.Time
+	pending       int
+	lastPrune     time.Time
+}
+
+func NewLimiter(maxPerIP, joinsPerIP int, joinWindow, joinCooldown time.Duration, maxPending int) *Limiter {
+	return &Limiter{
+		MaxPerIP:      maxPerIP,
+		JoinsPerIP:    joinsPerIP,
+		JoinWindow:    joinWindow,
+		JoinCooldown:  joinCooldown,
+		MaxPending:    maxPending,
+		now:           time.Now,
+		mu:            new(sync.Mutex),
+		active:        map[string]int{},
+		joins:         map[string][]time.Time{},
+		cooldownUntil: map[string]time.Time{},
+	}
+}
+
+// Accept registers a new connection from ip
+// On success, release must be called once the connection is closed
+func (l *Limiter) Accept(ip string) (release func(), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	if until, found := l.cooldownUntil[ip]; found {
+		if now.Before(until) {
+			return nil, JoinRateError
+		}
+		delete(l.cooldownUntil, ip)
+	}
+
+	// checked first so that connections refused by the cap do not count as joins
+	if l.MaxPerIP > 0 && l.active[ip] >= l.MaxPerIP {
+		return nil, TooManyConnectionsError
+	}
+
+	if l.JoinsPerIP > 0 {
+		recent := l.joins[ip][:0]
+		for _, v := range l.joins[ip] {
+			if now.Sub(v) < l.JoinWindow {
+				recent = append(recent, v)
+			}
+		}
+		recent = append(recent, now)
+		l.joins[ip] = recent
+		if len(recent) > l.JoinsPerIP {
+			l.cooldownUntil[ip] = now.Add(l.JoinCooldown)
+			delete(l.joins, ip)
+			return nil, JoinRateError
+		}
+	}
+
+	l.active[ip]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.active[ip]--; l.active[ip] <= 0 {
+				delete(l.active, ip)
+			}
+		})
+	}, nil
+}
+
+// BeginHandshake reserves one of the pending handshake slots
+// On success, end must be called once the handshake is over, whether it succeeded or not
+func (l *Limiter) BeginHandshake() (end func(), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.MaxPending > 0 && l.pending >= l.MaxPending {
+		return nil, TooManyPendingError
+	}
+	l.pending++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.pending--
+		})
+	}, nil
+}
+
+// drops join history that can no longer matter so the maps don't grow forever, must be called with mu held
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.JoinWindow {
+		return
+	}
+	l.lastPrune = now
+	for ip, times := range l.joins {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= l.JoinWindow {
+			delete(l.joins, ip)
+		}
+	}
+	for ip, until := range l.cooldownUntil {
+		if !now.Before(until) {
+			delete(l.cooldownUntil, ip)
+		}
+	}
+}
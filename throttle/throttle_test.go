@@ -0,0 +1,65 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(2, 3, time.Minute, 5*time.Minute, 1)
+	l.now = func() time.Time { return now }
+
+	release1, err := l.Accept("1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Accept("1.2.3.4"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Accept("1.2.3.4"); !errors.Is(err, TooManyConnectionsError) {
+		t.Fatalf("expected connection cap, got %v", err)
+	}
+	release1()
+	release1() // releasing twice must not free two slots
+
+	// the refused connection didn't count as a join, so this is the third
+	release3, err := l.Accept("1.2.3.4")
+	if err != nil {
+		t.Fatalf("a connection refused by the cap counted as a join: %v", err)
+	}
+	if _, err := l.Accept("1.2.3.4"); !errors.Is(err, TooManyConnectionsError) {
+		t.Fatalf("expected connection cap, got %v", err)
+	}
+	release3()
+
+	// fourth join within the window
+	if _, err := l.Accept("1.2.3.4"); !errors.Is(err, JoinRateError) {
+		t.Fatalf("expected join rate limit, got %v", err)
+	}
+	if _, err := l.Accept("5.6.7.8"); err != nil {
+		t.Fatalf("other addresses should not be affected, got %v", err)
+	}
+
+	now = now.Add(4 * time.Minute)
+	if _, err := l.Accept("1.2.3.4"); !errors.Is(err, JoinRateError) {
+		t.Fatalf("expected cooldown, got %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := l.Accept("1.2.3.4"); err != nil {
+		t.Fatalf("cooldown should be over, got %v", err)
+	}
+
+	end, err := l.BeginHandshake()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.BeginHandshake(); !errors.Is(err, TooManyPendingError) {
+		t.Fatalf("expected pending cap, got %v", err)
+	}
+	end()
+	if _, err := l.BeginHandshake(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -34,3 +34,12 @@ func (c *ConcurrentSlice) Snapshot(b []byte) {
 func (c *ConcurrentSlice) Len() int {
 	return len(c.data)
 }
+
+// Swap sets the value at index and returns the previous one
+func (c *ConcurrentSlice) Swap(index int, value byte) byte {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	old := c.data[index]
+	c.data[index] = value
+	return old
+}
@@ -0,0 +1,72 @@
+package world
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"marmalade/packets/outbound"
+)
+
+// DisconnectReason describes why a connection is being closed by the server
+type DisconnectReason struct {
+	Kind    string // short identifier used in logs
+	Message string // shown to the client, trimmed to 64 characters
+}
+
+var (
+	ReasonServerFull         = DisconnectReason{"server_full", "The server is full!"}
+	ReasonInvalidPacket      = DisconnectReason{"invalid_packet", "Invalid packet received."}
+	ReasonBadIdentification  = DisconnectReason{"bad_identification", "Failed to read your identification packet."}
+	ReasonMapSendFailed      = DisconnectReason{"map_send_failed", "Failed to send the map."}
+	ReasonInvalidUsername    = DisconnectReason{"invalid_username", "Invalid username."}
+	ReasonAlreadyOnline      = DisconnectReason{"already_online", "Someone with your username is already online."}
+	ReasonLoggedInElsewhere  = DisconnectReason{"logged_in_elsewhere", "You logged in from another location."}
+	ReasonNotWhitelisted     = DisconnectReason{"not_whitelisted", "You are not on the whitelist of this server."}
+	ReasonTooManyConnections = DisconnectReason{"too_many_connections", "Too many connections from your address."}
+	ReasonJoinThrottled      = DisconnectReason{"join_throttled", "You are joining too quickly, try again later."}
+	ReasonServerBusy         = DisconnectReason{"server_busy", "The server is busy, try again later."}
+	ReasonLoginTimeout       = DisconnectReason{"login_timeout", "You took too long to log in."}
+	ReasonLoginFailed        = DisconnectReason{"login_failed", "Too many failed login attempts."}
+	ReasonNotVerified        = DisconnectReason{"not_verified", "Failed to verify your username, try signing in again."}
+)
+
+// ReasonUnsupportedProtocol is used for clients speaking a protocol version the server doesn't know
+func ReasonUnsupportedProtocol(version uint8) DisconnectReason {
+	return DisconnectReason{"unsupported_protocol", fmt.Sprintf("Unsupported protocol version %v.", version)}
+}
+
+// Banned is used for banned players, message describes the ban
+func Banned(message string) DisconnectReason {
+	return DisconnectReason{"banned", message}
+}
+
+// Kicked creates a reason for a disconnect requested by a person or moderation tool
+func Kicked(text string) DisconnectReason {
+	if text == "" {
+		text = "You have been kicked."
+	}
+	return DisconnectReason{"kicked", text}
+}
+
+func (r DisconnectReason) String() string {
+	return fmt.Sprintf("%v (%v)", r.Kind, r.Message)
+}
+
+// Disconnect sends the reason to the client, flushes it out and closes the connection
+// Safe to call from any goroutine, the connection's read loop will then fail and clean up after itself
+func Disconnect(conn net.Conn, w *outbound.AFCBW, reason DisconnectReason) {
+	log.Printf("[INFO] Disconnecting %v: %v", conn.RemoteAddr().String(), reason)
+	if err := w.SendDisconnectPlayer(reason.Message); err != nil {
+		log.Printf("[ERROR] Failed to send disconnect packet to %v: %v", conn.RemoteAddr().String(), err)
+	} else if err := w.Flush(); err != nil {
+		log.Printf("[ERROR] Failed to flush disconnect packet to %v: %v", conn.RemoteAddr().String(), err)
+	}
+	w.Close()
+	_ = conn.Close()
+}
+
+// Kick disconnects the player with the given reason
+func (p *Player) Kick(reason DisconnectReason) {
+	Disconnect(p.Conn, p.Writer, reason)
+}
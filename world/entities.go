@@ -0,0 +1,58 @@
+package world
+
+// Classic clients treat entity IDs as signed bytes, and -1 (255) refers to themselves,
+// so only 0 to 127 can be used for other players
+const maxClientEntityIDs = 128
+
+// entityTable maps the IDs of the players one client can see to the entity IDs that client knows them by
+// Guarded by PlayersMu
+type entityTable struct {
+	ids  map[int]uint8 // player ID to client entity ID
+	free []uint8       // unused client entity IDs, the least recently released first
+}
+
+func newEntityTable(size int) *entityTable {
+	if size > maxClientEntityIDs {
+		size = maxClientEntityIDs
+	}
+	t := &entityTable{ids: map[int]uint8{}, free: make([]uint8, size)}
+	for i := range t.free {
+		t.free[i] = uint8(i)
+	}
+	return t
+}
+
+// assign gives the player a client entity ID, returns false if the client can't see any more players
+func (t *entityTable) assign(playerID int) (uint8, bool) {
+	if id, found := t.ids[playerID]; found {
+		return id, true
+	}
+	if len(t.free) == 0 {
+		return 0, false
+	}
+	id := t.free[0]
+	t.free = t.free[1:]
+	t.ids[playerID] = id
+	return id, true
+}
+
+// release frees the player's client entity ID, which must be despawned before it is reused
+// IDs are reused in the order they were released, so a freed ID is reused as late as possible
+func (t *entityTable) release(playerID int) (uint8, bool) {
+	id, found := t.ids[playerID]
+	if !found {
+		return 0, false
+	}
+	delete(t.ids, playerID)
+	t.free = append(t.free, id)
+	return id, true
+}
+
+func (t *entityTable) lookup(playerID int) (uint8, bool) {
+	id, found := t.ids[playerID]
+	return id, found
+}
+
+func (t *entityTable) full() bool {
+	return len(t.free) == 0
+}
@@ -0,0 +1,64 @@
+package world
+
+import (
+	"testing"
+
+	"marmalade/classicworld"
+	"marmalade/config"
+)
+
+func TestEntityTableAllocation(t *testing.T) {
+	table := newEntityTable(1000)
+	seen := map[uint8]bool{}
+	for player := 0; player < maxClientEntityIDs; player++ {
+		id, ok := table.assign(player)
+		if !ok {
+			t.Fatalf("ran out of entity IDs after %v players", player)
+		}
+		if id >= maxClientEntityIDs || id == 255 || seen[id] {
+			t.Fatalf("player %v got the entity ID %v", player, id)
+		}
+		seen[id] = true
+	}
+	if _, ok := table.assign(maxClientEntityIDs); ok || !table.full() {
+		t.Fatal("got more than 128 entity IDs")
+	}
+	if id, ok := table.assign(7); !ok || id != 7 {
+		t.Fatalf("assigning a player again changed their ID to %v", id)
+	}
+
+	// freed IDs are reused in the order they were freed
+	first, _ := table.release(20)
+	second, _ := table.release(10)
+	if _, ok := table.release(20); ok {
+		t.Fatal("released a player twice")
+	}
+	if id, _ := table.assign(500); id != first {
+		t.Fatalf("expected the first freed ID %v, got %v", first, id)
+	}
+	if id, _ := table.assign(501); id != second {
+		t.Fatalf("expected the second freed ID %v, got %v", second, id)
+	}
+}
+
+func TestVisiblePlayersRefill(t *testing.T) {
+	defer func(old int) { config.MaxVisiblePlayers = old }(config.MaxVisiblePlayers)
+	config.MaxVisiblePlayers = 2
+
+	w := NewWorld("crowded", classicworld.New("crowded", 16, 16, 16))
+	observer := newTestPlayer(t, "observer", w)
+	SpawnOtherPlayers(observer)
+	others := make([]*Player, 3)
+	for i := range others {
+		others[i] = newTestPlayer(t, string(rune('a'+i)), w)
+		SpawnOtherPlayers(others[i])
+	}
+	if !sees(observer, others[0]) || !sees(observer, others[1]) || sees(observer, others[2]) {
+		t.Fatal("expected the observer to see the first 2 players only")
+	}
+
+	RemovePlayer(others[0])
+	if !sees(observer, others[2]) {
+		t.Fatal("the freed entity ID wasn't used for the player the observer couldn't see")
+	}
+}
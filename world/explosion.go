@@ -0,0 +1,60 @@
+package world
+
+import (
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/config"
+	"marmalade/packets/outbound"
+)
+
+// block types explosions don't destroy, see SetBlastImmune
+var blastImmune [256]bool
+
+// SetBlastImmune keeps explosions from destroying the block type, see config.BlastImmune
+func SetBlastImmune(blockType byte) {
+	blastImmune[blockType] = true
+}
+
+// Ignite explodes the TNT at x, y, z after config.TNTFuse, unless it is gone by then
+// by is the player who lit it, or empty if it wasn't a player, see Explode
+func (w *World) Ignite(by string, x, y, z int) {
+	time.AfterFunc(config.TNTFuse, func() {
+		if w.blockAt(x, y, z) == blocks.TNT {
+			w.Explode(by, x, y, z)
+		}
+	})
+}
+
+// Explode destroys the blocks within config.BlastRadius of x, y, z and returns how many it destroyed
+// TNT in the blast explodes as well, up to config.MaxBlastChain explosions in total, and blocks in zones are spared
+// Every destroyed block is sent to the players in the world at once, and recorded as changed by the player by, see SetBlocks
+func (w *World) Explode(by string, x, y, z int) int {
+	radius := config.BlastRadius
+	destroyed := map[int]bool{}
+	var changes []outbound.SetBlock
+
+	centers := [][3]int{{x, y, z}}
+	for i := 0; i < len(centers) && i < config.MaxBlastChain; i++ {
+		cx, cy, cz := centers[i][0], centers[i][1], centers[i][2]
+		w.forCube(cx, cy, cz, radius, func(x, y, z int) {
+			dx, dy, dz := x-cx, y-cy, z-cz
+			if dx*dx+dy*dy+dz*dz > radius*radius+radius { // a little rounder than radius*radius
+				return
+			}
+			pos := w.position(uint16(x), uint16(y), uint16(z))
+			b := w.Blocks.Get(pos)
+			if destroyed[pos] || b == blocks.Air || blastImmune[b] || w.ZoneAt(x, y, z) != "" {
+				return
+			}
+			destroyed[pos] = true
+			changes = append(changes, outbound.SetBlock{X: uint16(x), Y: uint16(y), Z: uint16(z), BlockType: blocks.Air})
+			if b == blocks.TNT && (x != cx || y != cy || z != cz) {
+				centers = append(centers, [3]int{x, y, z})
+			}
+		})
+	}
+
+	w.SetBlocks(by, changes)
+	return len(changes)
+}
@@ -0,0 +1,77 @@
+package world
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/classicworld"
+	"marmalade/config"
+	"marmalade/packets/outbound"
+	"marmalade/ranks"
+)
+
+func newStoneWorld(t *testing.T) *World {
+	level := classicworld.New("stone", 32, 32, 32)
+	for i := range level.BlockArray {
+		level.BlockArray[i] = blocks.Stone
+	}
+	w := NewWorld("stone", level)
+	if err := w.SetPhysicsMode(PhysicsOff); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestExplodeSphere(t *testing.T) {
+	w := newStoneWorld(t)
+	r := config.BlastRadius
+	w.Explode("", 16, 16, 16)
+
+	if w.GetBlock(16, 16, 16) != blocks.Air || w.GetBlock(uint16(16+r), 16, 16) != blocks.Air {
+		t.Fatal("blocks within the radius weren't destroyed")
+	}
+	if w.GetBlock(uint16(16+r+1), 16, 16) != blocks.Stone || w.GetBlock(uint16(16+r), uint16(16+r), 16) != blocks.Stone {
+		t.Fatal("blocks outside of the sphere were destroyed")
+	}
+}
+
+func TestExplodeChainsAndSpares(t *testing.T) {
+	w := newStoneWorld(t)
+	r := config.BlastRadius
+	SetBlastImmune(blocks.Bedrock)
+	if err := w.AddZone(Zone{Name: "protected", Min: [3]uint16{15, 16, 16}, Max: [3]uint16{15, 16, 16}}); err != nil {
+		t.Fatal(err)
+	}
+
+	w.SetBlock(16, 16, 17, blocks.Bedrock)
+	w.SetBlock(uint16(16+r), 16, 16, blocks.TNT)
+	w.Explode("", 16, 16, 16)
+
+	if w.GetBlock(16, 16, 17) != blocks.Bedrock || w.GetBlock(15, 16, 16) != blocks.Stone {
+		t.Fatal("immune or protected blocks were destroyed")
+	}
+	if w.GetBlock(uint16(16+2*r), 16, 16) != blocks.Air {
+		t.Fatal("TNT in the blast didn't explode")
+	}
+}
+
+func TestExplodeSendsOneBatch(t *testing.T) {
+	w := newStoneWorld(t)
+	buf := new(bytes.Buffer)
+	writer := outbound.NewAFCBW(buf, time.Hour)
+	p := &Player{Username: "watcher", World: w, Rank: &ranks.Rank{}, Authenticated: true, Writer: writer}
+	if err := AddPlayer(p); err != nil {
+		t.Fatal(err)
+	}
+	defer RemovePlayer(p)
+
+	n := w.Explode("", 16, 16, 16)
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != n*8 { // a set block packet is 8 bytes
+		t.Fatalf("expected %v set block packets, got %v bytes", n, buf.Len())
+	}
+}
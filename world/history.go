@@ -0,0 +1,67 @@
+package world
+
+import (
+	"fmt"
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/history"
+	"marmalade/packets/outbound"
+)
+
+// History returns the block changes players made in the world
+func (w *World) History() *history.Log {
+	return w.history
+}
+
+// Revert undoes the changes, which must be newest first, recording the undoing as changes by the player by
+// Changes to blocks that have been changed again since are skipped, and so are changes that undid others
+// Stops after n changes, n < 0 for no limit, and returns how many were undone
+func (w *World) Revert(by string, changes []history.Change, n int) int {
+	current := map[int]byte{} // blocks as they are after the reverts so far
+	var reverts []outbound.SetBlock
+	for _, v := range changes {
+		if n >= 0 && len(reverts) >= n {
+			break
+		}
+		if v.Revert || !w.InBounds(int(v.X), int(v.Y), int(v.Z)) {
+			continue
+		}
+		pos := w.position(v.X, v.Y, v.Z)
+		b, found := current[pos]
+		if !found {
+			b = w.Blocks.Get(pos)
+		}
+		if b != v.New {
+			continue
+		}
+		current[pos] = v.Old
+		reverts = append(reverts, outbound.SetBlock{X: v.X, Y: v.Y, Z: v.Z, BlockType: v.Old})
+	}
+	w.setBlocks(by, true, reverts)
+	return len(reverts)
+}
+
+// the number of changes SendBlockHistory shows
+const aboutChanges = 5
+
+// SendBlockHistory tells the player what the block at x, y, z is, and who changed it last
+func SendBlockHistory(player *Player, x, y, z uint16) {
+	w := player.World
+	changes := w.history.At(x, y, z, aboutChanges)
+	msg := fmt.Sprintf("[System] Block %v %v %v is %v.", x, y, z, blocks.Name(w.GetBlock(x, y, z)))
+	if len(changes) == 0 {
+		msg += " Nobody has changed it."
+	}
+	_ = SendLargeMessage(player, msg)
+	for _, v := range changes {
+		action := "placed " + blocks.Name(v.New)
+		if v.New == blocks.Air {
+			action = "deleted " + blocks.Name(v.Old)
+		}
+		if v.Revert {
+			action += " by undoing"
+		}
+		_ = SendLargeMessage(player, fmt.Sprintf("- %v %v, %v ago", v.Player, action, time.Since(v.Time).Round(time.Second)))
+	}
+}
@@ -0,0 +1,59 @@
+package world
+
+import (
+	"testing"
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/classicworld"
+)
+
+func TestRevert(t *testing.T) {
+	w := NewWorld("history", classicworld.New("history", 8, 8, 8))
+	if err := w.SetPhysicsMode(PhysicsOff); err != nil {
+		t.Fatal(err)
+	}
+	w.SetBlockBy("alice", 1, 1, 1, blocks.Stone)
+	w.SetBlockBy("alice", 1, 1, 1, blocks.Glass)
+	w.SetBlockBy("alice", 2, 1, 1, blocks.Stone)
+	w.SetBlockBy("bob", 2, 1, 1, blocks.Bricks) // alice's stone is gone, so undoing it does nothing
+	w.SetBlock(3, 1, 1, blocks.Water)           // not made by a player
+
+	if n := w.Revert("alice", w.History().By("alice", time.Time{}, -1), 1); n != 1 || w.GetBlock(1, 1, 1) != blocks.Stone {
+		t.Fatalf("undoing 1 change undid %v, left %v", n, blocks.Name(w.GetBlock(1, 1, 1)))
+	}
+	if n := w.Revert("alice", w.History().By("alice", time.Time{}, -1), -1); n != 1 || w.GetBlock(1, 1, 1) != blocks.Air {
+		t.Fatalf("undoing the rest undid %v, left %v", n, blocks.Name(w.GetBlock(1, 1, 1)))
+	}
+	if w.GetBlock(2, 1, 1) != blocks.Bricks {
+		t.Fatal("undo reverted a block someone else changed since")
+	}
+	if n := w.Revert("alice", w.History().By("alice", time.Time{}, -1), -1); n != 0 {
+		t.Fatalf("undoing again undid %v changes, undoing shouldn't be undone", n)
+	}
+	if at := w.History().At(3, 1, 1, 10); len(at) != 1 || at[0].Player != NatureName {
+		t.Fatalf("a change not made by a player wasn't recorded as made by %v: %+v", NatureName, at)
+	}
+	if at := w.History().At(1, 1, 1, 10); len(at) != 4 || !at[0].Revert || at[3].New != blocks.Stone {
+		t.Fatalf("wrong history of 1 1 1: %+v", at)
+	}
+}
+
+func TestRevertPhysics(t *testing.T) {
+	w := newPhysicsWorld(t, PhysicsInfinite)
+	w.SetBlockBy("griefer", 3, 6, 3, blocks.Sand)
+	w.SetBlockBy("griefer", 5, 7, 5, blocks.Water)
+	tick(w, 40)
+	if w.GetBlock(3, 0, 3) != blocks.Sand || count(w, isWater) < 2 {
+		t.Fatal("the sand didn't fall or the water didn't spread")
+	}
+	if at := w.History().At(3, 0, 3, 1); len(at) != 1 || at[0].Player != "griefer" {
+		t.Fatalf("the fallen sand wasn't recorded as placed by the griefer: %+v", at)
+	}
+
+	w.Revert("admin", w.History().By("griefer", time.Time{}, -1), -1)
+	tick(w, 40)
+	if n := count(w, func(b byte) bool { return b != blocks.Air }); n != 0 {
+		t.Fatalf("rolling back left %v blocks", n)
+	}
+}
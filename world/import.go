@@ -0,0 +1,30 @@
+package world
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+
+	"marmalade/importer"
+)
+
+// ImportWorld creates a world from a map of another server, see importer.Import
+// The world is named after the file if name is empty
+func ImportWorld(path, name string) (*World, error) {
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := checkNewName(name); err != nil {
+		return nil, err
+	}
+	level, levelErr := importer.Import(path)
+	if levelErr != nil {
+		return nil, levelErr
+	}
+	w, err := CreateFrom(name, level)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] Imported map %v as %v", path, w.path)
+	return w, nil
+}
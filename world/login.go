@@ -0,0 +1,22 @@
+package world
+
+import "marmalade/ranks"
+
+// IsAuthenticated reports whether the player may play, which is false until they log in when accounts are required
+// Must not be called with PlayersMu held
+func (p *Player) IsAuthenticated() bool {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	return p.Authenticated
+}
+
+// SetAuthenticated unfreezes the player and gives them their own rank, until then they only have the default rank
+// Their client is told right away whether they are an operator
+func (p *Player) SetAuthenticated() {
+	rank := ranks.Of(p.Username)
+	PlayersMu.Lock()
+	p.Authenticated = true
+	p.Rank = rank
+	PlayersMu.Unlock()
+	_ = p.Writer.SendUpdateUserType(rank.OP)
+}
@@ -0,0 +1,46 @@
+package world
+
+import (
+	"marmalade/classicworld/nbt"
+)
+
+// the compound in the metadata of worlds that this server keeps its own settings in
+const metadataKey = "marmalade"
+
+// getMeta returns a setting from the world's metadata compound
+// Returned compounds must not be modified, use setMeta with a copy instead
+func (w *World) getMeta(key string) (nbt.Value, bool) {
+	w.metaMu.Lock()
+	defer w.metaMu.Unlock()
+	own, _ := w.level.Metadata[metadataKey].(nbt.Compound)
+	v, found := own[key]
+	return v, found
+}
+
+// setMeta changes a setting in the world's metadata compound, which is saved with the world
+func (w *World) setMeta(key string, v nbt.Value) {
+	w.metaMu.Lock()
+	defer w.metaMu.Unlock()
+	// the compound is replaced instead of modified, so that snapshots taken for saving never change
+	own, _ := w.level.Metadata[metadataKey].(nbt.Compound)
+	updated := nbt.Compound{}
+	for k, vv := range own {
+		updated[k] = vv
+	}
+	updated[key] = v
+	if w.level.Metadata == nil {
+		w.level.Metadata = nbt.Compound{}
+	}
+	w.level.Metadata[metadataKey] = updated
+}
+
+// metadataSnapshot returns a copy of the world's metadata to be saved
+func (w *World) metadataSnapshot() nbt.Compound {
+	w.metaMu.Lock()
+	defer w.metaMu.Unlock()
+	out := nbt.Compound{}
+	for k, v := range w.level.Metadata {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,362 @@
+package world
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/config"
+)
+
+// Physics modes of a world
+const (
+	PhysicsOff      = "off"      // nothing moves
+	PhysicsInfinite = "infinite" // liquids spread into every free space next to and below them, like in Minecraft Classic
+	PhysicsFinite   = "finite"   // liquids move instead of spreading, so there is never more of them than was placed
+)
+
+var UnknownPhysicsModeError = errors.New("unknown physics mode, use off, infinite or finite")
+
+// ValidPhysicsMode reports whether mode is one of the physics modes
+func ValidPhysicsMode(mode string) bool {
+	return mode == PhysicsOff || mode == PhysicsInfinite || mode == PhysicsFinite
+}
+
+const (
+	// ticks between block updates of each kind of block
+	fallDelay   = 1
+	waterDelay  = 2
+	lavaDelay   = 8
+	spongeDelay = 1
+	tntDelay    = 1
+
+	spongeRadius = 2 // sponges keep water out of the 5x5x5 cube around them
+
+	finiteSearchDistance = 4 // how far finite liquids look for a way down
+)
+
+// physicsQueue holds the scheduled block updates of a world
+type physicsQueue struct {
+	mu      sync.Mutex
+	mode    string
+	tick    int64
+	seq     int64 // keeps updates of the same tick in the order they were scheduled
+	updates updateHeap
+	pending map[int]bool // positions in updates, each is only scheduled once at a time
+}
+
+type scheduledUpdate struct {
+	tick int64
+	seq  int64
+	pos  int
+	by   string // who the changes the update makes are recorded as made by, see SetBlocks
+}
+
+type updateHeap []scheduledUpdate
+
+func (h updateHeap) Len() int { return len(h) }
+func (h updateHeap) Less(i, j int) bool {
+	if h[i].tick != h[j].tick {
+		return h[i].tick < h[j].tick
+	}
+	return h[i].seq < h[j].seq
+}
+func (h updateHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *updateHeap) Push(x interface{}) { *h = append(*h, x.(scheduledUpdate)) }
+func (h *updateHeap) Pop() interface{} {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
+
+// loads the physics mode of the world from its metadata, or config.Physics if it has none
+func (w *World) initPhysics() {
+	mode := config.Physics
+	if v, found := w.getMeta("Physics"); found {
+		if s, ok := v.(string); ok && ValidPhysicsMode(s) {
+			mode = s
+		}
+	}
+	w.physics = &physicsQueue{mode: mode, pending: map[int]bool{}}
+}
+
+// PhysicsMode returns the physics mode of the world
+func (w *World) PhysicsMode() string {
+	w.physics.mu.Lock()
+	defer w.physics.mu.Unlock()
+	return w.physics.mode
+}
+
+// SetPhysicsMode changes the physics mode of the world and saves it with the world
+// Turning physics off drops every scheduled block update
+func (w *World) SetPhysicsMode(mode string) error {
+	if !ValidPhysicsMode(mode) {
+		return UnknownPhysicsModeError
+	}
+	w.setMeta("Physics", mode)
+	w.physics.mu.Lock()
+	defer w.physics.mu.Unlock()
+	w.physics.mode = mode
+	if mode == PhysicsOff {
+		w.physics.updates = nil
+		w.physics.pending = map[int]bool{}
+	}
+	return nil
+}
+
+func (w *World) physicsLoop() {
+	ticker := time.NewTicker(config.PhysicsTickInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.tickPhysics()
+	}
+}
+
+// tickPhysics runs the block updates that are due, at most config.PhysicsBudget of them
+// The rest stay scheduled for the next tick
+func (w *World) tickPhysics() {
+	q := w.physics
+	q.mu.Lock()
+	q.tick++
+	var due []scheduledUpdate
+	for len(q.updates) > 0 && q.updates[0].tick <= q.tick && len(due) < config.PhysicsBudget {
+		u := heap.Pop(&q.updates).(scheduledUpdate)
+		delete(q.pending, u.pos)
+		due = append(due, u)
+	}
+	mode := q.mode
+	q.mu.Unlock()
+
+	// updates change blocks, which schedule more updates, so the lock can't be held here
+	for _, u := range due {
+		x, y, z := w.coordinates(u.pos)
+		w.updateBlock(x, y, z, mode, u.by)
+	}
+}
+
+// schedules an update of the block at x, y, z if it is one that physics moves
+// The changes the update makes are recorded as made by by, so that undoing a player's changes also undoes what they set off
+func (w *World) scheduleUpdate(x, y, z int, by string) {
+	if !w.InBounds(x, y, z) {
+		return
+	}
+	var delay int64
+	switch w.blockAt(x, y, z) {
+	case blocks.Sand, blocks.Gravel:
+		delay = fallDelay
+	case blocks.Water, blocks.FlowingWater:
+		delay = waterDelay
+	case blocks.Lava, blocks.FlowingLava:
+		delay = lavaDelay
+	case blocks.Sponge:
+		delay = spongeDelay
+	case blocks.TNT:
+		delay = tntDelay
+	default:
+		return
+	}
+
+	pos := w.position(uint16(x), uint16(y), uint16(z))
+	q := w.physics
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.mode == PhysicsOff || q.pending[pos] || len(q.updates) >= config.PhysicsMaxQueue {
+		return
+	}
+	q.seq++
+	heap.Push(&q.updates, scheduledUpdate{tick: q.tick + delay, seq: q.seq, pos: pos, by: by})
+	q.pending[pos] = true
+}
+
+// schedules updates of the block at x, y, z and the blocks next to it, after by changed it from old
+func (w *World) blockChanged(by string, x, y, z int, old byte) {
+	radius := 1
+	if old == blocks.Sponge { // the water the sponge kept out can flow back in
+		radius = spongeRadius + 1
+	}
+	for dy := -radius; dy <= radius; dy++ {
+		for dz := -radius; dz <= radius; dz++ {
+			for dx := -radius; dx <= radius; dx++ {
+				// only the blocks touching a face, unless a sponge was removed
+				if radius == 1 && abs(dx)+abs(dy)+abs(dz) > 1 {
+					continue
+				}
+				w.scheduleUpdate(x+dx, y+dy, z+dz, by)
+			}
+		}
+	}
+}
+
+func (w *World) updateBlock(x, y, z int, mode, by string) {
+	switch b := w.blockAt(x, y, z); b {
+	case blocks.Sand, blocks.Gravel:
+		if below := w.blockAt(x, y-1, z); below == blocks.Air || isLiquid(below) {
+			w.setBlock(by, x, y, z, blocks.Air)
+			w.setBlock(by, x, y-1, z, b)
+		}
+	case blocks.Sponge:
+		w.forCube(x, y, z, spongeRadius, func(x, y, z int) {
+			if isWater(w.blockAt(x, y, z)) {
+				w.setBlock(by, x, y, z, blocks.Air)
+			}
+		})
+	case blocks.Water, blocks.FlowingWater, blocks.Lava, blocks.FlowingLava:
+		w.flow(x, y, z, b, mode, by)
+	case blocks.TNT:
+		if w.touches(x, y, z, isLava) {
+			w.Ignite(by, x, y, z)
+		}
+	}
+}
+
+func (w *World) flow(x, y, z int, b byte, mode, by string) {
+	if isWater(b) {
+		if w.spongeNear(x, y, z) {
+			w.setBlock(by, x, y, z, blocks.Air)
+			return
+		}
+	} else if w.touches(x, y, z, isWater) { // lava that meets water hardens
+		if b == blocks.Lava {
+			w.setBlock(by, x, y, z, blocks.Obsidian)
+		} else {
+			w.setBlock(by, x, y, z, blocks.Cobblestone)
+		}
+		return
+	}
+
+	if mode == PhysicsFinite {
+		w.flowFinite(x, y, z, b, by)
+		return
+	}
+	spread := blocks.FlowingWater
+	if !isWater(b) {
+		spread = blocks.FlowingLava
+	}
+	for _, d := range flowDirections {
+		tx, ty, tz := x+d[0], y+d[1], z+d[2]
+		if w.canFlowInto(tx, ty, tz, b) {
+			w.setBlock(by, tx, ty, tz, spread)
+		}
+	}
+}
+
+// below first, then the sides
+var flowDirections = [...][3]int{{0, -1, 0}, {1, 0, 0}, {-1, 0, 0}, {0, 0, 1}, {0, 0, -1}}
+
+// flowFinite moves the liquid down, or one step towards the nearest place it can go down from
+func (w *World) flowFinite(x, y, z int, b byte, by string) {
+	if w.canFlowInto(x, y-1, z, b) {
+		w.setBlock(by, x, y, z, blocks.Air)
+		w.setBlock(by, x, y-1, z, b)
+		return
+	}
+
+	// breadth first search along the ground, remembering the first step of the way to each place
+	type step struct{ x, z, firstX, firstZ int }
+	visited := map[[2]int]bool{{x, z}: true}
+	frontier := []step{{x, z, 0, 0}}
+	for distance := 0; distance < finiteSearchDistance && len(frontier) > 0; distance++ {
+		var next []step
+		for _, s := range frontier {
+			for _, d := range flowDirections[1:] {
+				tx, tz := s.x+d[0], s.z+d[2]
+				if visited[[2]int{tx, tz}] || !w.canFlowInto(tx, y, tz, b) {
+					continue
+				}
+				visited[[2]int{tx, tz}] = true
+				first := s
+				if distance == 0 {
+					first = step{firstX: tx, firstZ: tz}
+				}
+				if w.canFlowInto(tx, y-1, tz, b) {
+					w.setBlock(by, x, y, z, blocks.Air)
+					w.setBlock(by, first.firstX, y, first.firstZ, b)
+					return
+				}
+				next = append(next, step{tx, tz, first.firstX, first.firstZ})
+			}
+		}
+		frontier = next
+	}
+}
+
+// whether liquid b may flow into x, y, z
+func (w *World) canFlowInto(x, y, z int, b byte) bool {
+	if !w.InBounds(x, y, z) || w.blockAt(x, y, z) != blocks.Air {
+		return false
+	}
+	return !isWater(b) || !w.spongeNear(x, y, z)
+}
+
+func (w *World) spongeNear(x, y, z int) bool {
+	found := false
+	w.forCube(x, y, z, spongeRadius, func(x, y, z int) {
+		found = found || w.blockAt(x, y, z) == blocks.Sponge
+	})
+	return found
+}
+
+// whether a block next to a face of x, y, z matches
+func (w *World) touches(x, y, z int, match func(byte) bool) bool {
+	for _, d := range [...][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}} {
+		if match(w.blockAt(x+d[0], y+d[1], z+d[2])) {
+			return true
+		}
+	}
+	return false
+}
+
+// calls f for every in bounds block in the cube around x, y, z
+func (w *World) forCube(x, y, z, radius int, f func(x, y, z int)) {
+	for cy := y - radius; cy <= y+radius; cy++ {
+		for cz := z - radius; cz <= z+radius; cz++ {
+			for cx := x - radius; cx <= x+radius; cx++ {
+				if w.InBounds(cx, cy, cz) {
+					f(cx, cy, cz)
+				}
+			}
+		}
+	}
+}
+
+// blockAt returns the block at x, y, z, out of bounds is bedrock so that nothing moves out of the world
+func (w *World) blockAt(x, y, z int) byte {
+	if !w.InBounds(x, y, z) {
+		return blocks.Bedrock
+	}
+	return w.GetBlock(uint16(x), uint16(y), uint16(z))
+}
+
+func (w *World) setBlock(by string, x, y, z int, b byte) {
+	w.SetBlockBy(by, uint16(x), uint16(y), uint16(z), b)
+}
+
+// calculates x, y, z from a Blocks index
+func (w *World) coordinates(pos int) (x, y, z int) {
+	x = pos % int(w.XSize)
+	z = pos / int(w.XSize) % int(w.ZSize)
+	y = pos / (int(w.XSize) * int(w.ZSize))
+	return x, y, z
+}
+
+func isWater(b byte) bool {
+	return b == blocks.Water || b == blocks.FlowingWater
+}
+
+func isLava(b byte) bool {
+	return b == blocks.Lava || b == blocks.FlowingLava
+}
+
+func isLiquid(b byte) bool {
+	return isWater(b) || isLava(b)
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
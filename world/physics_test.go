@@ -0,0 +1,124 @@
+package world
+
+import (
+	"testing"
+
+	"marmalade/blocks"
+	"marmalade/classicworld"
+)
+
+func newPhysicsWorld(t *testing.T, mode string) *World {
+	w := NewWorld("physics", classicworld.New("physics", 8, 8, 8))
+	if err := w.SetPhysicsMode(mode); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func tick(w *World, n int) {
+	for i := 0; i < n; i++ {
+		w.tickPhysics()
+	}
+}
+
+func count(w *World, match func(byte) bool) int {
+	n := 0
+	for i := 0; i < w.Blocks.Len(); i++ {
+		if match(w.Blocks.Get(i)) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestSandFalls(t *testing.T) {
+	w := newPhysicsWorld(t, PhysicsInfinite)
+	w.SetBlock(3, 6, 3, blocks.Sand)
+	tick(w, 20)
+	if w.GetBlock(3, 6, 3) != blocks.Air || w.GetBlock(3, 0, 3) != blocks.Sand {
+		t.Fatal("sand didn't fall to the bottom")
+	}
+}
+
+func TestInfiniteWaterSpreads(t *testing.T) {
+	w := newPhysicsWorld(t, PhysicsInfinite)
+	w.SetBlock(3, 0, 3, blocks.Water)
+	tick(w, 50)
+	if n := count(w, isWater); n != 8*8 {
+		t.Fatalf("water should cover the floor, got %v blocks", n)
+	}
+}
+
+func TestFiniteWaterKeepsVolume(t *testing.T) {
+	w := newPhysicsWorld(t, PhysicsFinite)
+	w.SetBlock(1, 3, 2, blocks.Water)
+	w.SetBlock(2, 3, 2, blocks.Water)
+	w.SetBlock(1, 0, 2, blocks.Stone) // it has to flow around this one
+	tick(w, 100)
+	if n := count(w, isWater); n != 2 {
+		t.Fatalf("finite water should keep its volume of 2, got %v", n)
+	}
+	for y := uint16(1); y < 8; y++ {
+		for z := uint16(0); z < 8; z++ {
+			for x := uint16(0); x < 8; x++ {
+				if isWater(w.GetBlock(x, y, z)) {
+					t.Fatalf("water stayed up at %v %v %v", x, y, z)
+				}
+			}
+		}
+	}
+}
+
+func TestSpongeAbsorbs(t *testing.T) {
+	w := newPhysicsWorld(t, PhysicsInfinite)
+	w.SetBlock(3, 0, 3, blocks.Water)
+	tick(w, 50)
+	w.SetBlock(0, 1, 0, blocks.Sponge)
+	tick(w, 50)
+	for z := uint16(0); z <= 2; z++ {
+		for x := uint16(0); x <= 2; x++ {
+			if b := w.GetBlock(x, 0, z); b != blocks.Air {
+				t.Fatalf("sponge didn't absorb %v at %v 0 %v", blocks.Name(b), x, z)
+			}
+		}
+	}
+	if !isWater(w.GetBlock(3, 0, 3)) {
+		t.Fatal("sponge absorbed water out of its range")
+	}
+
+	// the water flows back once the sponge is gone
+	w.SetBlock(0, 1, 0, blocks.Air)
+	tick(w, 50)
+	if n := count(w, isWater); n != 8*8 {
+		t.Fatalf("water should cover the floor again, got %v blocks", n)
+	}
+}
+
+func TestLavaHardens(t *testing.T) {
+	w := newPhysicsWorld(t, PhysicsOff)
+	w.SetBlock(3, 0, 3, blocks.Lava)
+	w.SetBlock(5, 0, 3, blocks.FlowingLava)
+	if err := w.SetPhysicsMode(PhysicsInfinite); err != nil {
+		t.Fatal(err)
+	}
+	w.SetBlock(4, 0, 3, blocks.Water)
+	tick(w, 20)
+	if b := w.GetBlock(3, 0, 3); b != blocks.Obsidian {
+		t.Fatalf("lava should harden into obsidian, got %v", blocks.Name(b))
+	}
+	if b := w.GetBlock(5, 0, 3); b != blocks.Cobblestone {
+		t.Fatalf("flowing lava should harden into cobblestone, got %v", blocks.Name(b))
+	}
+}
+
+func TestPhysicsOff(t *testing.T) {
+	w := newPhysicsWorld(t, PhysicsOff)
+	w.SetBlock(3, 6, 3, blocks.Sand)
+	tick(w, 20)
+	if w.GetBlock(3, 6, 3) != blocks.Sand {
+		t.Fatal("sand fell with physics off")
+	}
+	if v, _ := w.getMeta("Physics"); v != PhysicsOff {
+		t.Fatalf("physics mode should be saved in the metadata, got %v", v)
+	}
+}
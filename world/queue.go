@@ -0,0 +1,104 @@
+package world
+
+import (
+	"errors"
+	"strings"
+
+	"marmalade/config"
+)
+
+var QueueFullError = errors.New("join queue is full")
+
+// QueueTicket is a place in the join queue
+type QueueTicket struct {
+	player   *Player
+	admitted chan error // receives nil once the player has been added, or why they couldn't be
+}
+
+// players waiting for a slot, guarded by PlayersMu
+var queue []*QueueTicket
+
+// Enqueue puts the player at the back of the join queue, fails with QueueFullError if config.MaxQueueLength is reached
+func Enqueue(player *Player) (*QueueTicket, error) {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	if len(queue) >= config.MaxQueueLength {
+		return nil, QueueFullError
+	}
+	t := &QueueTicket{player: player, admitted: make(chan error, 1)}
+	queue = append(queue, t)
+	return t, nil
+}
+
+// Admitted receives once the player leaves the queue by being added with AddPlayer's rules
+func (t *QueueTicket) Admitted() <-chan error {
+	return t.admitted
+}
+
+// Position returns the 1-based position in the queue, or 0 if the ticket is no longer queued
+func (t *QueueTicket) Position() int {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	for i, v := range queue {
+		if v == t {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Leave removes the ticket from the queue
+// Returns false if it had already been admitted, in which case the player must be removed with RemovePlayer
+func (t *QueueTicket) Leave() bool {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	for i, v := range queue {
+		if v == t {
+			queue = append(queue[:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// QueuedPlayers returns the players waiting in the join queue, in order
+func QueuedPlayers() []*Player {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	out := make([]*Player, len(queue))
+	for i, v := range queue {
+		out[i] = v.player
+	}
+	return out
+}
+
+// FindConnected returns the online player with the given username (case insensitive) like FindPlayer,
+// or the player waiting in the join queue with it, so that moderation reaches queued players too
+func FindConnected(username string) *Player {
+	if p := FindPlayer(username); p != nil {
+		return p
+	}
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	for _, v := range queue {
+		if strings.EqualFold(username, v.player.Username) {
+			return v.player
+		}
+	}
+	return nil
+}
+
+// admits queued players in order while there is space for them, must be called with PlayersMu held
+// Players allowed to use reserved slots may be admitted ahead of players that can't use them
+func admitQueued() {
+	for i := 0; i < len(queue); {
+		t := queue[i]
+		err := addPlayer(t.player)
+		if errors.Is(err, ServerFullError) {
+			i++
+			continue
+		}
+		queue = append(queue[:i], queue[i+1:]...)
+		t.admitted <- err
+	}
+}
@@ -0,0 +1,84 @@
+package world
+
+import (
+	"math/rand"
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/config"
+	"marmalade/generator"
+)
+
+// saplings grow on one in this many of their random ticks
+const saplingGrowChance = 5
+
+func (w *World) randomTickLoop() {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ticker := time.NewTicker(config.RandomTickInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.randomTick(r)
+	}
+}
+
+// randomTick updates config.RandomTickSpeed blocks picked by r for every 16x16x16 blocks of the world
+// Grass, dirt and plants change depending on whether they are lit, and saplings grow into trees
+// The same r on the same blocks always makes the same changes
+func (w *World) randomTick(r *rand.Rand) {
+	n := w.Blocks.Len() / (16 * 16 * 16) * config.RandomTickSpeed
+	if n == 0 && config.RandomTickSpeed > 0 { // worlds smaller than one section still get ticks
+		n = config.RandomTickSpeed
+	}
+	for i := 0; i < n; i++ {
+		x, y, z := w.coordinates(r.Intn(w.Blocks.Len()))
+		switch w.blockAt(x, y, z) {
+		case blocks.Dirt:
+			if w.lit(x, y, z) {
+				w.setBlock(NatureName, x, y, z, blocks.Grass)
+			}
+		case blocks.Grass:
+			if !w.lit(x, y, z) {
+				w.setBlock(NatureName, x, y, z, blocks.Dirt)
+			}
+		case blocks.Dandelion, blocks.Rose:
+			if !w.lit(x, y, z) {
+				w.setBlock(NatureName, x, y, z, blocks.Air)
+			}
+		case blocks.BrownMushroom, blocks.RedMushroom:
+			if w.lit(x, y, z) {
+				w.setBlock(NatureName, x, y, z, blocks.Air)
+			}
+		case blocks.Sapling:
+			w.growSapling(x, y, z, r)
+		}
+	}
+}
+
+func (w *World) growSapling(x, y, z int, r *rand.Rand) {
+	if below := w.blockAt(x, y-1, z); below != blocks.Grass && below != blocks.Dirt {
+		w.setBlock(NatureName, x, y, z, blocks.Air)
+		return
+	}
+	if !w.lit(x, y, z) || r.Intn(saplingGrowChance) != 0 {
+		return
+	}
+	generator.Tree(treeAccess{w}, x, y, z, r)
+}
+
+// lit reports whether sunlight reaches the block at x, y, z, which it does if nothing above it blocks light
+func (w *World) lit(x, y, z int) bool {
+	for ty := y + 1; ty < int(w.YSize); ty++ {
+		if blocks.BlocksLight(w.blockAt(x, ty, z)) {
+			return false
+		}
+	}
+	return true
+}
+
+// treeAccess lets generator.Tree grow trees in a world, sending the blocks to its players
+type treeAccess struct {
+	*World
+}
+
+func (t treeAccess) Get(x, y, z int) byte    { return t.blockAt(x, y, z) }
+func (t treeAccess) Set(x, y, z int, b byte) { t.setBlock(NatureName, x, y, z, b) }
@@ -0,0 +1,78 @@
+package world
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"marmalade/blocks"
+	"marmalade/classicworld"
+)
+
+// a 16x16x16 world with a dirt floor, half of it covered by a stone roof, with plants on either side
+func newGardenWorld(t *testing.T) *World {
+	w := NewWorld("garden", classicworld.New("garden", 16, 16, 16))
+	if err := w.SetPhysicsMode(PhysicsOff); err != nil {
+		t.Fatal(err)
+	}
+	for z := uint16(0); z < 16; z++ {
+		for x := uint16(0); x < 16; x++ {
+			w.SetBlock(x, 0, z, blocks.Dirt)
+			if x >= 8 {
+				w.SetBlock(x, 10, z, blocks.Stone)
+			}
+		}
+	}
+	w.SetBlock(2, 1, 2, blocks.Rose)
+	w.SetBlock(2, 1, 4, blocks.RedMushroom)
+	w.SetBlock(12, 1, 2, blocks.Dandelion)
+	w.SetBlock(12, 1, 4, blocks.BrownMushroom)
+	w.SetBlock(4, 1, 8, blocks.Sapling)
+	return w
+}
+
+func snapshot(w *World) []byte {
+	b := make([]byte, w.Blocks.Len())
+	w.Blocks.Snapshot(b)
+	return b
+}
+
+func TestRandomTickGrowth(t *testing.T) {
+	w := newGardenWorld(t)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		w.randomTick(r)
+	}
+
+	for z := uint16(0); z < 16; z++ {
+		for x := uint16(0); x < 16; x++ {
+			want := blocks.Grass
+			if x >= 8 || (x == 4 && z == 8) { // under the roof or the tree
+				want = blocks.Dirt
+			}
+			if b := w.GetBlock(x, 0, z); b != want {
+				t.Fatalf("%v %v: want %v, got %v", x, z, blocks.Name(want), blocks.Name(b))
+			}
+		}
+	}
+	for _, v := range []struct {
+		x, z uint16
+		want byte
+	}{{2, 2, blocks.Rose}, {2, 4, blocks.Air}, {12, 2, blocks.Air}, {12, 4, blocks.BrownMushroom}, {4, 8, blocks.Log}} {
+		if b := w.GetBlock(v.x, 1, v.z); b != v.want {
+			t.Errorf("%v 1 %v: want %v, got %v", v.x, v.z, blocks.Name(v.want), blocks.Name(b))
+		}
+	}
+}
+
+func TestRandomTickDeterministic(t *testing.T) {
+	a, b := newGardenWorld(t), newGardenWorld(t)
+	ra, rb := rand.New(rand.NewSource(42)), rand.New(rand.NewSource(42))
+	for i := 0; i < 200; i++ {
+		a.randomTick(ra)
+		b.randomTick(rb)
+	}
+	if !bytes.Equal(snapshot(a), snapshot(b)) {
+		t.Fatal("the same seed grew different worlds")
+	}
+}
@@ -0,0 +1,28 @@
+package world
+
+import (
+	"marmalade/ranks"
+)
+
+// GetRank returns the player's rank, must not be called with PlayersMu held
+func (p *Player) GetRank() *ranks.Rank {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	return p.Rank
+}
+
+// Can reports whether the player's rank grants the permission node, must not be called with PlayersMu held
+func (p *Player) Can(node string) bool {
+	return p.GetRank().Has(node)
+}
+
+// SetRank saves the player's new rank and tells their client immediately whether they are an operator
+func SetRank(player *Player, rank *ranks.Rank) error {
+	if err := ranks.Set(player.Username, rank); err != nil {
+		return err
+	}
+	PlayersMu.Lock()
+	player.Rank = rank
+	PlayersMu.Unlock()
+	return player.Writer.SendUpdateUserType(rank.OP)
+}
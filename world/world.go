@@ -1,21 +1,21 @@
 package world
 
 import (
-	"bufio"
 	"bytes"
-	"compress/gzip"
-	"encoding/binary"
-	"io"
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
 	"strings"
 	"sync"
-	"time"
 
-	"marmalade/classicworld/nbt"
+	"marmalade/auth"
+	"marmalade/blocks"
 	"marmalade/config"
 	"marmalade/helpers"
+	"marmalade/packets"
 	"marmalade/packets/outbound"
+	"marmalade/ranks"
 )
 
 type (
@@ -23,10 +23,21 @@ type (
 		Username string
 		Position
 
-		ID uint8
-		OP bool
+		ID       int    // unique while the player is online, clients know other players by the IDs in entities instead
+		World    *World // guarded by PlayersMu, but only changed by the player's own goroutine, which may read it freely
+		Rank     *ranks.Rank
+		Protocol uint8 // protocol version of the client
 
+		Authenticated bool // false while the player still has to log in, see config.Accounts
+		LoginAttempts int
+
+		Inspecting bool   // the next block the player clicks is shown with SendBlockHistory instead of changed, only used by their own goroutine
+		zone       string // name of the zone the player was last in, guarded by PlayersMu
+
+		Conn   net.Conn
 		Writer *outbound.AFCBW
+
+		entities *entityTable
 	}
 
 	Position struct {
@@ -35,239 +46,264 @@ type (
 	}
 )
 
-var (
-	Players   = [255]*Player{}
-	PlayersMu = new(sync.Mutex)
-
-	Blocks *ConcurrentSlice
+// IP returns the IP address the player is connected from
+func (p *Player) IP() net.IP {
+	return auth.AddrIP(p.Conn.RemoteAddr())
+}
 
-	// Name string
-	// UUID []byte
+// BlockPosition returns the block the player's feet are in
+// Must be called from the player's own goroutine, or with PlayersMu held
+func (p *Player) BlockPosition() (x, y, z int) {
+	return int(p.X) / 32, (int(p.Y) - eyeHeight) / 32, int(p.Z) / 32
+}
 
-	XSize uint16
-	YSize uint16
-	ZSize uint16
+var (
+	Players      = map[int]*Player{}
+	PlayersMu    = new(sync.Mutex)
+	nextPlayerID = 0 // guarded by PlayersMu
+)
 
-	SpawnPos Position
+var (
+	ServerFullError        = errors.New("server is full")
+	DuplicateUsernameError = errors.New("a player with that username is already online")
 )
 
-func Initialize() {
-	worldFile, worldFileErr := os.Open(config.WorldPath)
-	if worldFileErr != nil {
-		panic(worldFileErr)
-	}
-	defer func() { _ = worldFile.Close() }()
-	// gzipR, gzipRErr := gzip.NewReader(worldFile)
-	// if gzipRErr != nil {
-	// 	panic(gzipRErr)
-	// }
-	// defer func() { _ = gzipR.Close() }()
-	bufR := bufio.NewReader(worldFile)
-
-	wNBT, _ /* wNBTName */, wNBTErr := nbt.Read(bufR)
-	if wNBTErr != nil {
-		panic(wNBTErr)
-	}
-
-	// Name = wNBT["Name"].(string)
-	// UUID = wNBT["UUID"].([]byte)
-
-	XSize = wNBT["X"].(uint16)
-	YSize = wNBT["Y"].(uint16)
-	ZSize = wNBT["Z"].(uint16)
-
-	spawnNBT := wNBT["Spawn"].(nbt.Compound)
-	SpawnPos.X = spawnNBT["X"].(uint16)
-	SpawnPos.Y = spawnNBT["Y"].(uint16)
-	SpawnPos.Z = spawnNBT["Z"].(uint16)
-	SpawnPos.Yaw = spawnNBT["H"].(uint8) // Heading is another name for yaw
-	SpawnPos.Pitch = spawnNBT["P"].(uint8)
-
-	Blocks = NewConcurrentSlice(wNBT["BlockArray"].([]byte))
-
-	log.Printf("[INFO] Loaded map %v", config.WorldPath)
-
-	go func() {
-		for {
-			time.Sleep(config.WorldSaveDelay)
-			if err := save(); err != nil {
-				log.Printf("[ERROR] Failed to save world: %v", err)
-			} else {
-				log.Print("[INFO] Successfully saved world.")
-			}
-		}
-	}()
+// AddPlayer assigns the player an ID and adds them to Players and their World, which is Main if it isn't set
+// Fails with ServerFullError if there is no space, or DuplicateUsernameError if someone with the same username is online
+// The last config.ReservedSlots slots are only available to players with the slot.reserved permission
+func AddPlayer(player *Player) error {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	return addPlayer(player)
 }
 
-func save() error {
-	// Write-only, because we're not going to read or append anything
-	// Create, because we want to create a new file if it didn't previously exist
-	worldScFile, worldScFileErr := os.OpenFile(config.WorldScratchPath, os.O_WRONLY|os.O_CREATE, os.ModePerm)
-	if worldScFileErr != nil {
-		return worldScFileErr
-	}
-	defer func() { _ = worldScFile.Close() }()
-	// buf0 := bufio.NewWriter(worldScFile)
-	// gzipW := gzip.NewWriter(buf0) // compression unexpected eof for some reason
-	bufW := bufio.NewWriter(worldScFile)
-
-	snapshot := snapshotBufferPool.Get().([]byte)
-	Blocks.Snapshot(snapshot)
-
-	if err := nbt.DoWrite(bufW,
-		nbt.WriteCompound("ClassicWorld"),
-		nbt.WriteShort("X", XSize),
-		nbt.WriteShort("Y", YSize),
-		nbt.WriteShort("Z", ZSize),
-		nbt.WriteCompound("Spawn"),
-		nbt.WriteShort("X", SpawnPos.X),
-		nbt.WriteShort("Y", SpawnPos.Y),
-		nbt.WriteShort("Z", SpawnPos.Z),
-		nbt.WriteByte("H", SpawnPos.Yaw),
-		nbt.WriteByte("P", SpawnPos.Pitch),
-		nbt.WriteEnd(), // Spawn
-		nbt.WriteByteArray("BlockArray", snapshot),
-		nbt.WriteCompound("Metadata"),
-		nbt.WriteString("Made_With", "marmalade"),
-		nbt.WriteEnd(), // Metadata
-		nbt.WriteEnd(), // ClassicWorld
-	); err != nil {
-		return err
-	}
-
-	// Close writers before renaming the files
-	// if err := buf0.Flush(); err != nil {
-	// 	return err
-	// }
-	// if err := gzipW.Close(); err != nil {
-	// 	return err
-	// }
-	if err := bufW.Flush(); err != nil {
-		return err
+// must be called with PlayersMu held
+func addPlayer(player *Player) error {
+	for _, v := range Players {
+		if strings.EqualFold(v.Username, player.Username) {
+			return DuplicateUsernameError
+		}
 	}
-	if err := worldScFile.Close(); err != nil {
-		return err
+	maxPlayers := config.MaxPlayers
+	if !player.Rank.Has("slot.reserved") {
+		maxPlayers -= config.ReservedSlots
 	}
-
-	// First rename WorldPath to WorldTempPath instead of deletion in case renaming WorldScratchPath to WorldPath fails
-	if err := os.Rename(config.WorldPath, config.WorldTempPath); err != nil {
-		return err
+	if len(Players) >= maxPlayers {
+		return ServerFullError
 	}
-	if err := os.Rename(config.WorldScratchPath, config.WorldPath); err != nil {
-		return err
+	if player.World == nil {
+		player.World = Main
 	}
+	player.ID = nextPlayerID
+	nextPlayerID++
+	player.entities = newEntityTable(config.MaxVisiblePlayers)
+	Players[player.ID] = player
+	player.World.players[player.ID] = player
+	return nil
+}
 
-	// Now we can safely delete the old world
-	return os.Remove(config.WorldTempPath)
+// PlayerCount returns the number of online players
+func PlayerCount() int {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	return len(Players)
 }
 
-// returns true if there is space to put another player
-func AddPlayer(player *Player) bool {
+// OnlinePlayers returns a snapshot of the online players
+func OnlinePlayers() []*Player {
 	PlayersMu.Lock()
 	defer PlayersMu.Unlock()
-	for i, v := range Players {
-		if v == nil {
-			Players[i] = player
-			player.ID = uint8(i)
-			return true
-		}
+	out := make([]*Player, 0, len(Players))
+	for _, v := range Players {
+		out = append(out, v)
 	}
-	return false
+	return out
 }
 
-func RemovePlayer(id uint8) {
+// FindPlayer returns the online player with the given username (case insensitive), or nil
+func FindPlayer(username string) *Player {
 	PlayersMu.Lock()
 	defer PlayersMu.Unlock()
-
 	for _, v := range Players {
-		if v != nil {
-			_ = v.Writer.SendDespawnPlayer(id)
+		if strings.EqualFold(username, v.Username) {
+			return v
 		}
 	}
-
-	Players[id] = nil
+	return nil
 }
 
-var snapshotBufferPool = sync.Pool{New: func() interface{} { return make([]byte, Blocks.Len()) }}
+// RemovePlayer despawns the player and frees their ID, does nothing if they were already removed
+func RemovePlayer(player *Player) {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
 
-func SendWorld(w *outbound.AFCBW) error {
-	if err := w.SendLevelInitialize(); err != nil {
+	if Players[player.ID] != player {
+		return
+	}
+	delete(Players, player.ID)
+	leaveWorld(player)
+
+	admitQueued()
+}
+
+// MovePlayer sends the player to another world and spawns them there
+// Must be called from the player's own goroutine, see Player.World
+func MovePlayer(player *Player, w *World) error {
+	PlayersMu.Lock()
+	if Players[player.ID] != player {
+		PlayersMu.Unlock()
+		return nil
+	}
+	leaveWorld(player)
+	// the client forgets every entity when it gets a new level, so start over
+	player.entities = newEntityTable(config.MaxVisiblePlayers)
+	player.World = w
+	player.Position = w.SpawnPos
+	player.zone = ""
+	w.players[player.ID] = player
+	PlayersMu.Unlock()
+
+	if err := w.SendTo(player.Writer); err != nil {
+		return err
+	}
+	if err := player.Writer.SendSpawnPlayer(255, player.Username, player.X, player.Y, player.Z, player.Yaw, player.Pitch); err != nil {
 		return err
 	}
+	SpawnOtherPlayers(player)
+	return nil
+}
 
-	pipeR, pipeW := io.Pipe()
-	defer func() { _ = pipeR.Close() }()
-	defer func() { _ = pipeW.Close() }()
-
-	bufW := bufio.NewWriter(pipeW)
-	gzipW := gzip.NewWriter(bufW)
-
-	go func() {
-		snapshot := snapshotBufferPool.Get().([]byte)
-		defer snapshotBufferPool.Put(snapshot)
-		Blocks.Snapshot(snapshot)
-		_ = binary.Write(gzipW, binary.BigEndian, uint32(len(snapshot)))
-		_, _ = gzipW.Write(snapshot)
-		_ = gzipW.Close()
-		_ = bufW.Flush()
-	}()
-
-	readBuf := make([]byte, 1024)
-	for {
-		n, err := pipeR.Read(readBuf)
-		if err != nil {
-			return err
-		}
-		if sErr := w.SendLevelDataChunk(uint16(n), readBuf, 50); sErr != nil {
-			return sErr
-		}
+// removes the player from their world and despawns them for the players there, must be called with PlayersMu held
+func leaveWorld(player *Player) {
+	delete(player.World.players, player.ID)
 
-		if n < len(readBuf) {
-			break
+	for _, v := range player.World.players {
+		if id, visible := v.entities.release(player.ID); visible {
+			_ = v.Writer.SendDespawnPlayer(id)
+			// the freed entity ID may let them see someone they couldn't before
+			spawnVisible(v)
 		}
 	}
-
-	return w.SendLevelFinalize(XSize, YSize, ZSize)
 }
 
-func HandleSetBlock(x, y, z uint16, mode, blockType byte) {
-	pos := position(x, y, z)
-	if pos >= Blocks.Len() || pos < 0 { // bounds check
-		return
+// CanPlace reports whether the player may place (mode 1) or delete (mode 0) the block type
+func CanPlace(player *Player, mode, blockType byte) bool {
+	if !player.IsAuthenticated() {
+		return false
 	}
 	if mode == 0x00 {
-		blockType = 0x00
+		return player.Can("delete")
 	}
-	Blocks.Set(pos, blockType)
+	return player.Can("build") && player.Can(fmt.Sprintf("place.%v", blockType))
+}
 
-	PlayersMu.Lock()
-	defer PlayersMu.Unlock()
+// block types players may neither place nor delete without the forbidden.bypass permission, see SetForbiddenBlock
+var forbidden [256]bool
 
-	for _, v := range Players {
-		if v != nil {
-			_ = v.Writer.SendSetBlock(x, y, z, blockType)
-		}
+// SetForbiddenBlock keeps players without the forbidden.bypass permission from placing or deleting the block type
+// Forbidding air keeps them from placing air, see config.ForbiddenBlocks
+func SetForbiddenBlock(blockType byte) {
+	forbidden[blockType] = true
+}
+
+// IsForbidden reports whether the block type is forbidden, see SetForbiddenBlock
+func IsForbidden(blockType byte) bool {
+	return forbidden[blockType]
+}
+
+// HandleSetBlock validates a set block packet from the player's client, then applies it with PlaceBlock
+// Changes with an unknown mode or out of the player's reach are refused, see config.MaxReach
+// If the change is refused, the real block is sent back to the client and false is returned
+// Must be called from the player's own goroutine, see Player.World
+func HandleSetBlock(player *Player, x, y, z uint16, mode, blockType byte) bool {
+	w := player.World
+	if !w.InBounds(int(x), int(y), int(z)) {
+		return false
+	}
+	if player.Inspecting {
+		player.Inspecting = false
+		_ = player.Writer.SendSetBlock(x, y, z, w.GetBlock(x, y, z))
+		SendBlockHistory(player, x, y, z)
+		return false
 	}
+	if (mode != 0x00 && mode != 0x01) || !inReach(player, x, y, z) {
+		_ = player.Writer.SendSetBlock(x, y, z, w.GetBlock(x, y, z))
+		return false
+	}
+	return PlaceBlock(player, x, y, z, mode, blockType)
+}
+
+// whether the middle of the block is within config.MaxReach blocks of the player's eyes, must be called from the player's own goroutine
+func inReach(player *Player, x, y, z uint16) bool {
+	if config.MaxReach <= 0 {
+		return true
+	}
+	dx := int64(x)*32 + 16 - int64(player.X)
+	dy := int64(y)*32 + 16 - int64(player.Y)
+	dz := int64(z)*32 + 16 - int64(player.Z)
+	reach := int64(config.MaxReach) * 32
+	return dx*dx+dy*dy+dz*dz <= reach*reach
 }
 
-// calculates the Blocks index from x, y, z
-func position(x, y, z uint16) int {
-	return int(y)*int(XSize)*int(ZSize) + int(z)*int(XSize) + int(x)
+// PlaceBlock applies a block change made by the player, such as by clicking or with /fill, and broadcasts it
+// The change is refused if the player's client doesn't know the block type, the player isn't allowed to make it,
+// the block is forbidden (see SetForbiddenBlock) or it is in a zone they may not build in
+// If the change is refused, the real block is sent back to the player and false is returned
+// Must be called from the player's own goroutine, see Player.World
+func PlaceBlock(player *Player, x, y, z uint16, mode, blockType byte) bool {
+	w := player.World
+	if !w.InBounds(int(x), int(y), int(z)) {
+		return false
+	}
+	if mode == 0x00 {
+		blockType = 0x00
+	}
+	current := w.GetBlock(x, y, z)
+	if blockType > packets.MaxBlock(player.Protocol) || !CanPlace(player, mode, blockType) {
+		_ = player.Writer.SendSetBlock(x, y, z, current)
+		return false
+	}
+	if ((mode == 0x01 && IsForbidden(blockType)) || (mode == 0x00 && IsForbidden(current))) && !player.Can("forbidden.bypass") {
+		_ = player.Writer.SendSetBlock(x, y, z, current)
+		return false
+	}
+	if ok, zone := w.CanBuildIn(player, int(x), int(y), int(z), int(x), int(y), int(z)); !ok {
+		_ = player.Writer.SendSetBlock(x, y, z, current)
+		_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] You may not build in zone %v.", zone))
+		return false
+	}
+	w.SetBlockBy(player.Username, x, y, z, blockType)
+	if blockType == blocks.TNT && config.IgnitePlacedTNT {
+		w.Ignite(player.Username, int(x), int(y), int(z))
+	}
+	return true
 }
 
 func HandlePositionAndOrientation(player *Player, x, y, z uint16, yaw, pitch uint8) {
 	PlayersMu.Lock()
 	defer PlayersMu.Unlock()
 
+	if !player.Authenticated { // frozen until they log in, send them back
+		_ = player.Writer.SendPositionAndOrientation(255, player.X, player.Y, player.Z, player.Yaw, player.Pitch)
+		return
+	}
+
 	player.X = x
 	player.Y = y
 	player.Z = z
 	player.Yaw = yaw
 	player.Pitch = pitch
 
-	for _, v := range Players {
-		if v != nil {
-			_ = v.Writer.SendPositionAndOrientation(player.ID, x, y, z, yaw, pitch)
+	for _, v := range player.World.players {
+		if id, visible := v.entities.lookup(player.ID); visible {
+			_ = v.Writer.SendPositionAndOrientation(id, x, y, z, yaw, pitch)
+		}
+	}
+
+	if zone := player.World.ZoneAt(player.BlockPosition()); zone != player.zone {
+		player.zone = zone
+		if zone != "" {
+			_ = player.Writer.SendMessageStr(fmt.Sprintf("[System] Entering zone %v.", zone))
 		}
 	}
 }
@@ -276,13 +312,44 @@ func SpawnOtherPlayers(newPlayer *Player) {
 	PlayersMu.Lock()
 	defer PlayersMu.Unlock()
 
-	// send player other players
-	for _, v := range Players {
-		if v != nil && v.ID != newPlayer.ID {
-			// send player other players
-			_ = newPlayer.Writer.SendSpawnPlayer(v.ID, v.Username, v.X, v.Y, v.Z, v.Yaw, v.Pitch)
+	for _, v := range newPlayer.World.players {
+		if v.ID != newPlayer.ID {
 			// send other players player
-			_ = v.Writer.SendSpawnPlayer(newPlayer.ID, newPlayer.Username, newPlayer.X, newPlayer.Y, newPlayer.Z, newPlayer.Yaw, newPlayer.Pitch)
+			spawnFor(v, newPlayer)
+		}
+	}
+	// send player other players
+	spawnVisible(newPlayer)
+}
+
+// spawns the player for the observer if they have a free entity ID, must be called with PlayersMu held
+func spawnFor(observer, player *Player) {
+	if _, visible := observer.entities.lookup(player.ID); visible {
+		return
+	}
+	if id, ok := observer.entities.assign(player.ID); ok {
+		_ = observer.Writer.SendSpawnPlayer(id, player.Username, player.X, player.Y, player.Z, player.Yaw, player.Pitch)
+	}
+}
+
+// spawns players the observer can't see yet until they run out of entity IDs, must be called with PlayersMu held
+// Players are spawned in the order they joined
+func spawnVisible(observer *Player) {
+	if observer.entities.full() {
+		return
+	}
+	players := observer.World.players
+	ids := make([]int, 0, len(players))
+	for id := range players {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		if observer.entities.full() {
+			return
+		}
+		if id != observer.ID {
+			spawnFor(observer, players[id])
 		}
 	}
 }
@@ -291,9 +358,7 @@ func BroadcastMessage(message string) {
 	PlayersMu.Lock()
 	defer PlayersMu.Unlock()
 	for _, v := range Players {
-		if v != nil {
-			_ = v.Writer.SendMessageStr(message)
-		}
+		_ = v.Writer.SendMessageStr(message)
 	}
 }
 
@@ -0,0 +1,245 @@
+package world
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/classicworld"
+	"marmalade/classicworld/nbt"
+	"marmalade/config"
+	"marmalade/packets"
+	"marmalade/packets/outbound"
+	"marmalade/ranks"
+)
+
+func newTestPlayer(t *testing.T, name string, w *World) *Player {
+	p := &Player{Username: name, World: w, Rank: &ranks.Rank{}, Authenticated: true, Writer: outbound.NewAFCBW(ioutil.Discard, time.Second)}
+	if err := AddPlayer(p); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { RemovePlayer(p) })
+	return p
+}
+
+func sees(observer, player *Player) bool {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	_, visible := observer.entities.lookup(player.ID)
+	return visible
+}
+
+func TestPlayersOnlySeeTheirWorld(t *testing.T) {
+	a := NewWorld("a", classicworld.New("a", 16, 16, 16))
+	b := NewWorld("b", classicworld.New("b", 16, 16, 16))
+
+	p1 := newTestPlayer(t, "p1", a)
+	SpawnOtherPlayers(p1)
+	p2 := newTestPlayer(t, "p2", a)
+	SpawnOtherPlayers(p2)
+	p3 := newTestPlayer(t, "p3", b)
+	SpawnOtherPlayers(p3)
+
+	if !sees(p1, p2) || !sees(p2, p1) {
+		t.Fatal("players in the same world should see each other")
+	}
+	if sees(p1, p3) || sees(p3, p1) || sees(p3, p2) {
+		t.Fatal("players in different worlds should not see each other")
+	}
+
+	if err := MovePlayer(p3, a); err != nil {
+		t.Fatal(err)
+	}
+	if !sees(p3, p1) || !sees(p3, p2) || !sees(p1, p3) || !sees(p2, p3) {
+		t.Fatal("a player moved into a world should see and be seen by its players")
+	}
+	if p3.Position != a.SpawnPos {
+		t.Fatalf("expected a moved player to be at the spawn %v, got %v", a.SpawnPos, p3.Position)
+	}
+
+	if err := MovePlayer(p1, b); err != nil {
+		t.Fatal(err)
+	}
+	if sees(p1, p2) || sees(p2, p1) || sees(p1, p3) || sees(p3, p1) {
+		t.Fatal("a player that left a world should no longer see or be seen by its players")
+	}
+	if a.PlayerCount() != 2 || b.PlayerCount() != 1 {
+		t.Fatalf("expected 2 and 1 players, got %v and %v", a.PlayerCount(), b.PlayerCount())
+	}
+}
+
+func TestSetBlockBounds(t *testing.T) {
+	w := NewWorld("bounds", classicworld.New("bounds", 4, 4, 4))
+	w.SetBlock(1, 2, 3, 7)
+	if w.GetBlock(1, 2, 3) != 7 {
+		t.Fatal("block wasn't set")
+	}
+	w.SetBlock(4, 0, 0, 7) // out of bounds, must not wrap around into the next row
+	if w.GetBlock(0, 0, 1) != 0 {
+		t.Fatal("out of bounds block was set")
+	}
+}
+
+func TestHandleSetBlockValidation(t *testing.T) {
+	w := NewWorld("validation", classicworld.New("validation", 32, 32, 32))
+	if err := w.SetPhysicsMode(PhysicsOff); err != nil {
+		t.Fatal(err)
+	}
+	w.SetBlock(10, 9, 10, blocks.Bedrock)
+	defer func(old [256]bool) { forbidden = old }(forbidden) // other tests expect nothing to be forbidden
+	SetForbiddenBlock(blocks.Air)
+	SetForbiddenBlock(blocks.Bedrock)
+
+	buf := new(bytes.Buffer)
+	p := &Player{
+		Username: "placer", World: w, Protocol: packets.ProtocolVersion7, Authenticated: true,
+		Position: Position{X: 10*32 + 16, Y: 10*32 + eyeHeight, Z: 10*32 + 16},
+		Rank:     &ranks.Rank{Permissions: []string{"build", "delete", "place.*"}},
+		Writer:   outbound.NewAFCBW(buf, time.Hour),
+	}
+	if err := AddPlayer(p); err != nil {
+		t.Fatal(err)
+	}
+	defer RemovePlayer(p)
+
+	for _, v := range []struct {
+		name            string
+		x, y, z         uint16
+		mode, blockType byte
+	}{
+		{"unknown mode", 11, 10, 10, 2, blocks.Stone},
+		{"out of reach", 10, 10, 20, 1, blocks.Stone},
+		{"unknown block", 11, 10, 10, 1, blocks.Obsidian + 1},
+		{"forbidden block", 11, 10, 10, 1, blocks.Bedrock},
+		{"air", 11, 10, 10, 1, blocks.Air},
+		{"deleting a forbidden block", 10, 9, 10, 0, blocks.Stone},
+	} {
+		buf.Reset()
+		if HandleSetBlock(p, v.x, v.y, v.z, v.mode, v.blockType) {
+			t.Errorf("%v: change was accepted", v.name)
+			continue
+		}
+		_ = p.Writer.Flush()
+		real := w.GetBlock(v.x, v.y, v.z)
+		if want := []byte{0x06, 0, byte(v.x), 0, byte(v.y), 0, byte(v.z), real}; !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%v: expected the real block to be resent, got %v", v.name, buf.Bytes())
+		}
+	}
+
+	if !HandleSetBlock(p, 11, 10, 10, 1, blocks.Obsidian) || w.GetBlock(11, 10, 10) != blocks.Obsidian {
+		t.Fatal("a valid change was refused")
+	}
+	p.Rank = &ranks.Rank{Permissions: []string{"*"}}
+	if !HandleSetBlock(p, 10, 9, 10, 0, blocks.Stone) {
+		t.Fatal("forbidden.bypass doesn't allow deleting forbidden blocks")
+	}
+}
+
+func TestMigrateLegacyWorld(t *testing.T) {
+	dir := t.TempDir()
+	oldDir, oldMain, oldPath := config.WorldsDir, config.MainWorld, config.LegacyWorldPath
+	defer func() { config.WorldsDir, config.MainWorld, config.LegacyWorldPath = oldDir, oldMain, oldPath }()
+	config.WorldsDir, config.MainWorld, config.LegacyWorldPath = dir, "legacy", filepath.Join(dir, "world.ucw")
+
+	// written the way older versions saved their world, with the spawn in 32nds of a block
+	blockArray := make([]byte, 4*8*4)
+	blockArray[1] = blocks.Stone
+	if err := classicworld.Save(config.LegacyWorldPath, config.LegacyWorldPath+"2", config.LegacyWorldPath+"_TMP", false,
+		nbt.WriteCompound("ClassicWorld"),
+		nbt.WriteShort("X", 4), nbt.WriteShort("Y", 8), nbt.WriteShort("Z", 4),
+		nbt.WriteCompound("Spawn"),
+		nbt.WriteShort("X", 2*32+16), nbt.WriteShort("Y", 5*32+eyeHeight), nbt.WriteShort("Z", 3*32+16),
+		nbt.WriteByte("H", 64), nbt.WriteByte("P", 0),
+		nbt.WriteEnd(),
+		nbt.WriteByteArray("BlockArray", blockArray),
+		nbt.WriteCompound("Metadata"), nbt.WriteString("Made_With", "marmalade"), nbt.WriteEnd(),
+		nbt.WriteEnd(),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := migrateLegacyWorld(); err != nil {
+		t.Fatal(err)
+	}
+	w, err := LoadWorld(filepath.Join(dir, "legacy.ucw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (Position{X: 2*32 + 16, Y: 5*32 + eyeHeight, Z: 3*32 + 16, Yaw: 64}); w.SpawnPos != expected {
+		t.Fatalf("expected the spawn %v, got %v", expected, w.SpawnPos)
+	}
+	if w.GetBlock(1, 0, 0) != blocks.Stone {
+		t.Fatal("the blocks weren't migrated")
+	}
+}
+
+func TestFindConnectedQueued(t *testing.T) {
+	w := NewWorld("queue", classicworld.New("queue", 16, 16, 16))
+	p := &Player{Username: "waiting", World: w, Rank: &ranks.Rank{}, Writer: outbound.NewAFCBW(ioutil.Discard, time.Second)}
+	ticket, err := Enqueue(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FindPlayer("WAITING") != nil || FindConnected("WAITING") != p {
+		t.Fatal("only FindConnected should find queued players")
+	}
+	if !ticket.Leave() || FindConnected("waiting") != nil || len(QueuedPlayers()) != 0 {
+		t.Fatal("a player that left the queue was still found")
+	}
+}
+
+func TestOldClientsOnlyGetKnownBlocks(t *testing.T) {
+	level := classicworld.New("old", 16, 16, 16)
+	for i := range level.BlockArray {
+		level.BlockArray[i] = byte(i % int(blocks.Count))
+	}
+	w := NewWorld("old", level)
+	if err := w.SetPhysicsMode(PhysicsOff); err != nil {
+		t.Fatal(err)
+	}
+	max := packets.MaxBlock(packets.ProtocolVersion5)
+
+	buf := new(bytes.Buffer)
+	writer := outbound.NewAFCBW(buf, time.Hour)
+	writer.SetProtocolVersion(packets.ProtocolVersion5)
+	if err := w.SendTo(writer); err != nil {
+		t.Fatal(err)
+	}
+	_ = writer.Flush()
+	data := buf.Bytes()[1:] // level initialize
+	var compressed []byte
+	for len(data) > 0 && data[0] == 0x03 { // level data chunks
+		compressed = append(compressed, data[3:3+(int(data[1])<<8|int(data[2]))]...)
+		data = data[1028:]
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range sent[4:] {
+		if b > max {
+			t.Fatalf("level data has %v at %v", blocks.Name(b), i)
+		}
+	}
+
+	buf.Reset()
+	p := &Player{Username: "old", World: w, Rank: &ranks.Rank{}, Authenticated: true, Writer: writer}
+	if err := AddPlayer(p); err != nil {
+		t.Fatal(err)
+	}
+	defer RemovePlayer(p)
+	w.SetBlock(1, 1, 1, blocks.Obsidian)
+	w.SetBlocks("", []outbound.SetBlock{{X: 2, Y: 1, Z: 1, BlockType: blocks.GoldBlock}})
+	_ = writer.Flush()
+	if got := buf.Bytes(); len(got) != 16 || got[7] > max || got[15] > max {
+		t.Fatalf("expected 2 set block packets with known blocks, got %v", got)
+	}
+}
@@ -0,0 +1,498 @@
+package world
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"marmalade/classicworld"
+	"marmalade/config"
+	"marmalade/generator"
+	"marmalade/history"
+	"marmalade/packets"
+	"marmalade/packets/outbound"
+)
+
+// World is a map that players can be in, each with its own blocks and players
+type World struct {
+	Name string
+
+	Blocks *ConcurrentSlice
+
+	XSize uint16
+	YSize uint16
+	ZSize uint16
+
+	SpawnPos Position
+
+	players map[int]*Player // the players in this world, guarded by PlayersMu
+
+	// everything about the world except for its blocks, which are kept in Blocks while it is loaded
+	level  *classicworld.World
+	metaMu sync.Mutex // guards level.Metadata, see getMeta
+
+	path         string // file the world is saved to, empty if it isn't saved
+	compressed   bool   // whether the file was gzip compressed when it was loaded
+	lastModified int64  // unix seconds, accessed atomically
+
+	physics *physicsQueue
+	history *history.Log // block changes made by players
+
+	zones   []Zone
+	zonesMu sync.RWMutex
+
+	snapshots sync.Pool
+}
+
+var (
+	worlds   = map[string]*World{} // by lowercase name
+	worldsMu = new(sync.RWMutex)
+
+	// Main is the world players join in, see config.MainWorld
+	Main *World
+)
+
+// NewWorld creates a world from a ClassicWorld map, which isn't saved anywhere
+// The world takes ownership of the map's BlockArray
+func NewWorld(name string, level *classicworld.World) *World {
+	w := &World{
+		Name:     name,
+		Blocks:   NewConcurrentSlice(level.BlockArray),
+		XSize:    level.X,
+		YSize:    level.Y,
+		ZSize:    level.Z,
+		SpawnPos: spawnPosition(level.Spawn),
+		players:  map[int]*Player{},
+		level:    level,
+		history:  history.New(),
+	}
+	if !level.LastModified.IsZero() {
+		w.lastModified = level.LastModified.Unix()
+	}
+	level.BlockArray = nil
+	w.initPhysics()
+	w.initZones()
+	w.snapshots.New = func() interface{} { return make([]byte, w.Blocks.Len()) }
+	return w
+}
+
+// players are positioned by their eyes, which are this many 32nds of a block above their feet
+const eyeHeight = 51
+
+// converts a spawn position back into the block coordinates it is saved in, see spawnPosition
+func spawnBlock(p Position) classicworld.Spawn {
+	y := uint16(0)
+	if p.Y > eyeHeight {
+		y = (p.Y - eyeHeight) / 32
+	}
+	return classicworld.Spawn{X: p.X / 32, Y: y, Z: p.Z / 32, H: p.Yaw, P: p.Pitch}
+}
+
+// converts a spawn in block coordinates into the middle of that block, in the 32nds of a block that positions are in
+func spawnPosition(s classicworld.Spawn) Position {
+	return Position{X: s.X*32 + 16, Y: s.Y*32 + eyeHeight, Z: s.Z*32 + 16, Yaw: s.H, Pitch: s.P} // H (heading) is another name for yaw
+}
+
+// LoadWorld loads the ClassicWorld file at path, the world is named after the file and saved back to it
+func LoadWorld(path string) (*World, error) {
+	wNBT, order, compressed, wNBTErr := classicworld.Load(path)
+	if wNBTErr != nil {
+		return nil, wNBTErr
+	}
+	level, levelErr := classicworld.Decode(wNBT, order)
+	if levelErr != nil {
+		return nil, levelErr
+	}
+	level.LastAccessed = time.Now()
+
+	w := NewWorld(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), level)
+	w.path = path
+	w.compressed = compressed
+	return w, nil
+}
+
+// Initialize loads every world in config.WorldsDir
+// If the main world isn't there, the world of older versions at config.LegacyWorldPath is moved there, see migrateLegacyWorld
+// Only if that doesn't exist either, the main world is generated
+func Initialize() {
+	if err := os.MkdirAll(config.WorldsDir, 0755); err != nil {
+		panic(err)
+	}
+	files, filesErr := ioutil.ReadDir(config.WorldsDir)
+	if filesErr != nil {
+		panic(filesErr)
+	}
+	for _, v := range files {
+		if ext := filepath.Ext(v.Name()); v.IsDir() || (ext != ".cw" && ext != ".ucw") {
+			continue
+		}
+		path := filepath.Join(config.WorldsDir, v.Name())
+		w, wErr := LoadWorld(path)
+		if wErr != nil { // one broken map shouldn't keep the others from loading
+			log.Printf("[ERROR] Failed to load map %v: %v", path, wErr)
+			continue
+		}
+		if err := Add(w); err != nil {
+			log.Printf("[ERROR] Failed to add map %v: %v", path, err)
+			continue
+		}
+		log.Printf("[INFO] Loaded map %v (compressed: %v)", path, w.compressed)
+	}
+
+	Main = Get(config.MainWorld)
+	if Main == nil {
+		w, wErr := migrateLegacyWorld()
+		if wErr != nil {
+			panic(wErr)
+		}
+		if w != nil {
+			if err := Add(w); err != nil {
+				panic(err)
+			}
+			Main = w
+		}
+	}
+	if Main == nil {
+		log.Printf("[INFO] Main world %v not found, generating it with %v", config.MainWorld, config.WorldGenerator)
+		w, wErr := Create(config.MainWorld, config.WorldGenerator,
+			uint16(config.WorldSizeX), uint16(config.WorldSizeY), uint16(config.WorldSizeZ), generator.ParseSeed(config.WorldSeed))
+		if wErr != nil {
+			panic(wErr)
+		}
+		Main = w
+	}
+}
+
+// migrateLegacyWorld saves the world at config.LegacyWorldPath as the main world in config.WorldsDir and returns it
+// Older versions kept the spawn in the 32nds of a block that positions are in, it is converted into block coordinates
+// The old file is left as it was, nil is returned if it doesn't exist
+func migrateLegacyWorld() (*World, error) {
+	if _, err := os.Stat(config.LegacyWorldPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	wNBT, order, _, wNBTErr := classicworld.Load(config.LegacyWorldPath)
+	if wNBTErr != nil {
+		return nil, wNBTErr
+	}
+	level, levelErr := classicworld.Decode(wNBT, order)
+	if levelErr != nil {
+		return nil, levelErr
+	}
+	s := level.Spawn
+	level.Spawn = spawnBlock(Position{X: s.X, Y: s.Y, Z: s.Z, Yaw: s.H, Pitch: s.P})
+	if level.Name == "" {
+		level.Name = config.MainWorld
+	}
+
+	w := NewWorld(config.MainWorld, level)
+	w.path = filepath.Join(config.WorldsDir, config.MainWorld+".ucw")
+	if err := w.save(); err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] Moved the world at %v into %v, the old file is no longer used and can be deleted", config.LegacyWorldPath, w.path)
+	return w, nil
+}
+
+var (
+	DuplicateWorldError   = errors.New("a world with that name already exists")
+	InvalidWorldNameError = errors.New("world names may only contain letters, digits, - and _")
+)
+
+// Create generates a new world with the named generator, see CreateFrom
+func Create(name, generatorName string, x, y, z uint16, seed int64) (*World, error) {
+	if err := checkNewName(name); err != nil {
+		return nil, err
+	}
+	level, levelErr := generator.Generate(generatorName, name, x, y, z, seed)
+	if levelErr != nil {
+		return nil, levelErr
+	}
+	w, err := CreateFrom(name, level)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] Generated map %v with %v, seed %v", w.path, generatorName, seed)
+	return w, nil
+}
+
+// CreateFrom saves a new world made from the ClassicWorld map to config.WorldsDir and adds it
+func CreateFrom(name string, level *classicworld.World) (*World, error) {
+	if err := checkNewName(name); err != nil {
+		return nil, err
+	}
+	w := NewWorld(name, level)
+	w.compressed = config.WorldFormat != "ucw"
+	w.path = filepath.Join(config.WorldsDir, name+".cw")
+	if !w.compressed {
+		w.path = filepath.Join(config.WorldsDir, name+".ucw")
+	}
+	if err := w.save(); err != nil {
+		return nil, err
+	}
+	if err := Add(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// checks that a new world can be created with the name
+func checkNewName(name string) error {
+	if !validName(name) {
+		return InvalidWorldNameError
+	}
+	if Get(name) != nil {
+		return DuplicateWorldError
+	}
+	// files of worlds that failed to load aren't overwritten either
+	for _, ext := range []string{".cw", ".ucw"} {
+		if _, err := os.Stat(filepath.Join(config.WorldsDir, name+ext)); err == nil {
+			return DuplicateWorldError
+		}
+	}
+	return nil
+}
+
+func validName(name string) bool {
+	if name == "" || len(name) > 64 {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Add makes the world available to players, starts its physics and random ticks, and starts saving it periodically if it has a file
+// Worlds with a file keep their history in a .history file next to it
+func Add(w *World) error {
+	worldsMu.Lock()
+	defer worldsMu.Unlock()
+	key := strings.ToLower(w.Name)
+	if _, found := worlds[key]; found {
+		return DuplicateWorldError
+	}
+	if w.path != "" {
+		log, logErr := history.Open(strings.TrimSuffix(w.path, filepath.Ext(w.path)) + ".history")
+		if logErr != nil {
+			return logErr
+		}
+		w.history = log
+	}
+	worlds[key] = w
+	go w.physicsLoop()
+	if config.RandomTickSpeed > 0 {
+		go w.randomTickLoop()
+	}
+	if w.path != "" {
+		go w.saveLoop()
+	}
+	return nil
+}
+
+// Get returns the world with the given name (case insensitive), or nil
+func Get(name string) *World {
+	worldsMu.RLock()
+	defer worldsMu.RUnlock()
+	return worlds[strings.ToLower(name)]
+}
+
+// All returns every world, sorted by name
+func All() []*World {
+	worldsMu.RLock()
+	defer worldsMu.RUnlock()
+	out := make([]*World, 0, len(worlds))
+	for _, v := range worlds {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// PlayerCount returns the number of players in the world
+func (w *World) PlayerCount() int {
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+	return len(w.players)
+}
+
+func (w *World) saveLoop() {
+	for {
+		time.Sleep(config.WorldSaveDelay)
+		if err := w.save(); err != nil {
+			log.Printf("[ERROR] Failed to save world %v: %v", w.Name, err)
+		} else {
+			log.Printf("[INFO] Successfully saved world %v.", w.Name)
+		}
+	}
+}
+
+// whether the world should be saved gzip compressed, according to config.WorldFormat
+func (w *World) saveCompressed() bool {
+	switch config.WorldFormat {
+	case "cw":
+		return true
+	case "ucw":
+		return false
+	default: // auto
+		return w.compressed
+	}
+}
+
+func (w *World) save() error {
+	snapshot := w.snapshots.Get().([]byte)
+	defer w.snapshots.Put(snapshot)
+	w.Blocks.Snapshot(snapshot)
+
+	l := *w.level
+	l.X, l.Y, l.Z = w.XSize, w.YSize, w.ZSize
+	l.Spawn = spawnBlock(w.SpawnPos)
+	l.BlockArray = snapshot
+	if t := atomic.LoadInt64(&w.lastModified); t != 0 {
+		l.LastModified = time.Unix(t, 0)
+	}
+	l.Metadata = w.metadataSnapshot()
+	l.Metadata["Made_With"] = "marmalade"
+
+	return classicworld.Save(w.path, w.path+"2", w.path+"_TMP", w.saveCompressed(), l.Encode())
+}
+
+// SendTo sends the blocks of the world to a client, block types it doesn't know are replaced, see packets.FallbackBlock
+func (w *World) SendTo(writer *outbound.AFCBW) error {
+	if err := writer.SendLevelInitialize(); err != nil {
+		return err
+	}
+
+	pipeR, pipeW := io.Pipe()
+	defer func() { _ = pipeR.Close() }()
+	defer func() { _ = pipeW.Close() }()
+
+	bufW := bufio.NewWriter(pipeW)
+	gzipW := gzip.NewWriter(bufW)
+
+	go func() {
+		snapshot := w.snapshots.Get().([]byte)
+		defer w.snapshots.Put(snapshot)
+		w.Blocks.Snapshot(snapshot)
+		if version := writer.ProtocolVersion(); packets.MaxBlock(version) < packets.MaxBlock(packets.CurrentProtocolVersion) {
+			table := packets.FallbackTable(version)
+			for i, b := range snapshot {
+				snapshot[i] = table[b]
+			}
+		}
+		_ = binary.Write(gzipW, binary.BigEndian, uint32(len(snapshot)))
+		_, _ = gzipW.Write(snapshot)
+		_ = gzipW.Close()
+		_ = bufW.Flush()
+		_ = pipeW.Close() // the end of the data
+	}()
+
+	// reads can return less than a chunk before the end, so chunks are filled up until the data ends
+	readBuf := make([]byte, 1024)
+	for {
+		n, err := io.ReadFull(pipeR, readBuf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		if sErr := writer.SendLevelDataChunk(uint16(n), readBuf, 50); sErr != nil {
+			return sErr
+		}
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+
+	return writer.SendLevelFinalize(w.XSize, w.YSize, w.ZSize)
+}
+
+// InBounds reports whether x, y, z is inside the world
+func (w *World) InBounds(x, y, z int) bool {
+	return x >= 0 && y >= 0 && z >= 0 && x < int(w.XSize) && y < int(w.YSize) && z < int(w.ZSize)
+}
+
+// GetBlock returns the block at x, y, z, which must be in bounds
+func (w *World) GetBlock(x, y, z uint16) byte {
+	return w.Blocks.Get(w.position(x, y, z))
+}
+
+// NatureName is who changes nobody made, such as grass spreading, are recorded as made by in the history
+// It isn't a valid username, so it never mixes with the changes of a player
+const NatureName = "#nature"
+
+// SetBlock changes the block at x, y, z, sends the change to the players in the world and schedules physics updates around it
+// The change is recorded in the history as made by NatureName, does nothing if x, y, z is out of bounds
+func (w *World) SetBlock(x, y, z uint16, blockType byte) {
+	w.SetBlockBy("", x, y, z, blockType)
+}
+
+// SetBlockBy changes a block like SetBlock, recording it in the history as changed by the player by
+func (w *World) SetBlockBy(by string, x, y, z uint16, blockType byte) {
+	w.SetBlocks(by, []outbound.SetBlock{{X: x, Y: y, Z: z, BlockType: blockType}})
+}
+
+// SetBlocks makes many block changes, like SetBlock, but sends them to each player at once
+// The changes are recorded in the history as made by the player by, or NatureName if by is empty
+// Changes that physics makes because of them are recorded as made by by as well
+func (w *World) SetBlocks(by string, changes []outbound.SetBlock) {
+	w.setBlocks(by, false, changes)
+}
+
+func (w *World) setBlocks(by string, revert bool, changes []outbound.SetBlock) {
+	if by == "" {
+		by = NatureName
+	}
+	now := time.Now()
+	var applied []outbound.SetBlock
+	var records []history.Change
+	for _, v := range changes {
+		if !w.InBounds(int(v.X), int(v.Y), int(v.Z)) {
+			continue
+		}
+		old := w.Blocks.Swap(w.position(v.X, v.Y, v.Z), v.BlockType)
+		if old == v.BlockType {
+			continue
+		}
+		applied = append(applied, v)
+		w.blockChanged(by, int(v.X), int(v.Y), int(v.Z), old)
+		records = append(records, history.Change{Time: now, Player: by, X: v.X, Y: v.Y, Z: v.Z, Old: old, New: v.BlockType, Revert: revert})
+	}
+	if len(applied) == 0 {
+		return
+	}
+	atomic.StoreInt64(&w.lastModified, now.Unix())
+	if len(records) > 0 {
+		if err := w.history.Add(records...); err != nil {
+			log.Printf("[ERROR] Failed to record block changes in world %v: %v", w.Name, err)
+		}
+	}
+
+	PlayersMu.Lock()
+	defer PlayersMu.Unlock()
+
+	for _, v := range w.players {
+		_ = v.Writer.SendSetBlocks(applied)
+	}
+}
+
+// calculates the Blocks index from x, y, z
+func (w *World) position(x, y, z uint16) int {
+	return int(y)*int(w.XSize)*int(w.ZSize) + int(z)*int(w.XSize) + int(x)
+}
@@ -0,0 +1,279 @@
+package world
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"marmalade/classicworld/nbt"
+	"marmalade/ranks"
+)
+
+// Zone is a cuboid of a world that only some players may build in
+// Explosions never destroy blocks in zones
+type Zone struct {
+	Name     string
+	Min, Max [3]uint16 // corners, both inclusive
+
+	Owners  []string // may build, and edit or delete the zone
+	Allow   []string // may build
+	Deny    []string // may not build, even if their rank is high enough
+	MinRank string   // lowest rank that may build, empty if only owners and allowed players may
+}
+
+var (
+	DuplicateZoneError   = errors.New("a zone with that name already exists")
+	ZoneNotFoundError    = errors.New("zone not found")
+	InvalidZoneNameError = errors.New("zone names may only contain letters, digits, - and _")
+	ZoneBoundsError      = errors.New("the zone must be inside the world")
+)
+
+// Contains reports whether x, y, z is in the zone
+func (zone *Zone) Contains(x, y, z int) bool {
+	return zone.intersects([3]int{x, y, z}, [3]int{x, y, z})
+}
+
+// whether the zone overlaps the cuboid from min to max
+func (zone *Zone) intersects(min, max [3]int) bool {
+	for i := range min {
+		if max[i] < int(zone.Min[i]) || min[i] > int(zone.Max[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsOwner reports whether the player owns the zone
+func (zone *Zone) IsOwner(username string) bool {
+	return containsName(zone.Owners, username)
+}
+
+// CanBuild reports whether the player may build in the zone, must not be called with PlayersMu held
+// Players with the zone.bypass permission may build in every zone
+func (zone *Zone) CanBuild(player *Player) bool {
+	rank := player.GetRank()
+	switch {
+	case rank.Has("zone.bypass"):
+		return true
+	case containsName(zone.Deny, player.Username):
+		return false
+	case zone.IsOwner(player.Username), containsName(zone.Allow, player.Username):
+		return true
+	}
+	if zone.MinRank == "" {
+		return false
+	}
+	min := ranks.Get(zone.MinRank)
+	return min != nil && rank.Level >= min.Level
+}
+
+func containsName(names []string, username string) bool {
+	for _, v := range names {
+		if strings.EqualFold(v, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// loads the zones of the world from its metadata
+func (w *World) initZones() {
+	v, found := w.getMeta("Zones")
+	if !found {
+		return
+	}
+	list, ok := v.(nbt.List)
+	if !ok {
+		log.Printf("[ERROR] Ignoring the zones of world %v, they aren't a list", w.Name)
+		return
+	}
+	for _, v := range list.Values {
+		c, ok := v.(nbt.Compound)
+		if !ok {
+			continue
+		}
+		z, ok := decodeZone(c)
+		if !ok {
+			log.Printf("[ERROR] Ignoring invalid zone %v in world %v", c["Name"], w.Name)
+			continue
+		}
+		w.zones = append(w.zones, z)
+	}
+}
+
+func decodeZone(c nbt.Compound) (Zone, bool) {
+	var z Zone
+	var ok [8]bool
+	z.Name, ok[0] = c["Name"].(string)
+	z.Min[0], ok[1] = c["X1"].(uint16)
+	z.Min[1], ok[2] = c["Y1"].(uint16)
+	z.Min[2], ok[3] = c["Z1"].(uint16)
+	z.Max[0], ok[4] = c["X2"].(uint16)
+	z.Max[1], ok[5] = c["Y2"].(uint16)
+	z.Max[2], ok[6] = c["Z2"].(uint16)
+	z.MinRank, ok[7] = c["MinRank"].(string)
+	for _, v := range ok {
+		if !v {
+			return z, false
+		}
+	}
+	z.Owners, z.Allow, z.Deny = decodeNames(c["Owners"]), decodeNames(c["Allow"]), decodeNames(c["Deny"])
+	return z, true
+}
+
+func decodeNames(v nbt.Value) []string {
+	list, _ := v.(nbt.List)
+	var out []string
+	for _, v := range list.Values {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func encodeZone(z Zone) nbt.Compound {
+	return nbt.Compound{
+		"Name":    z.Name,
+		"X1":      z.Min[0],
+		"Y1":      z.Min[1],
+		"Z1":      z.Min[2],
+		"X2":      z.Max[0],
+		"Y2":      z.Max[1],
+		"Z2":      z.Max[2],
+		"Owners":  encodeNames(z.Owners),
+		"Allow":   encodeNames(z.Allow),
+		"Deny":    encodeNames(z.Deny),
+		"MinRank": z.MinRank,
+	}
+}
+
+func encodeNames(names []string) nbt.List {
+	list := nbt.List{Type: nbt.TagString, Values: make([]nbt.Value, len(names))}
+	for i, v := range names {
+		list.Values[i] = v
+	}
+	return list
+}
+
+// stores the zones in the metadata of the world, must be called with zonesMu held
+func (w *World) saveZones() {
+	list := nbt.List{Type: nbt.TagCompound, Values: make([]nbt.Value, len(w.zones))}
+	for i, v := range w.zones {
+		list.Values[i] = encodeZone(v)
+	}
+	w.setMeta("Zones", list)
+}
+
+// Zones returns the zones of the world
+func (w *World) Zones() []Zone {
+	w.zonesMu.RLock()
+	defer w.zonesMu.RUnlock()
+	return append([]Zone(nil), w.zones...)
+}
+
+// GetZone returns the zone with the name (case insensitive)
+func (w *World) GetZone(name string) (Zone, bool) {
+	w.zonesMu.RLock()
+	defer w.zonesMu.RUnlock()
+	if i := w.zoneIndex(name); i >= 0 {
+		return w.zones[i], true
+	}
+	return Zone{}, false
+}
+
+// must be called with zonesMu held
+func (w *World) zoneIndex(name string) int {
+	for i, v := range w.zones {
+		if strings.EqualFold(v.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddZone adds the zone to the world, the order of its corners doesn't matter
+func (w *World) AddZone(z Zone) error {
+	if !validName(z.Name) {
+		return InvalidZoneNameError
+	}
+	for i := range z.Min {
+		if z.Min[i] > z.Max[i] {
+			z.Min[i], z.Max[i] = z.Max[i], z.Min[i]
+		}
+	}
+	if !w.InBounds(int(z.Max[0]), int(z.Max[1]), int(z.Max[2])) {
+		return ZoneBoundsError
+	}
+
+	w.zonesMu.Lock()
+	defer w.zonesMu.Unlock()
+	if w.zoneIndex(z.Name) >= 0 {
+		return DuplicateZoneError
+	}
+	w.zones = append(w.zones, z)
+	w.saveZones()
+	return nil
+}
+
+// EditZone changes the zone with the name, edit must not change its name or corners
+func (w *World) EditZone(name string, edit func(z *Zone)) error {
+	w.zonesMu.Lock()
+	defer w.zonesMu.Unlock()
+	i := w.zoneIndex(name)
+	if i < 0 {
+		return ZoneNotFoundError
+	}
+	// edited on a copy, so that the lists of zones returned by Zones never change
+	z := w.zones[i]
+	z.Owners = append([]string(nil), z.Owners...)
+	z.Allow = append([]string(nil), z.Allow...)
+	z.Deny = append([]string(nil), z.Deny...)
+	edit(&z)
+	w.zones[i] = z
+	w.saveZones()
+	return nil
+}
+
+// RemoveZone deletes the zone with the name
+func (w *World) RemoveZone(name string) error {
+	w.zonesMu.Lock()
+	defer w.zonesMu.Unlock()
+	i := w.zoneIndex(name)
+	if i < 0 {
+		return ZoneNotFoundError
+	}
+	w.zones = append(w.zones[:i:i], w.zones[i+1:]...)
+	w.saveZones()
+	return nil
+}
+
+// ZoneAt returns the name of the first zone that contains x, y, z, or an empty string
+func (w *World) ZoneAt(x, y, z int) string {
+	w.zonesMu.RLock()
+	defer w.zonesMu.RUnlock()
+	for i := range w.zones {
+		if w.zones[i].Contains(x, y, z) {
+			return w.zones[i].Name
+		}
+	}
+	return ""
+}
+
+// CanBuildIn reports whether the player may build everywhere in the cuboid between two corners
+// If not, the name of a zone they may not build in is returned as well
+// Must not be called with PlayersMu held
+func (w *World) CanBuildIn(player *Player, x1, y1, z1, x2, y2, z2 int) (bool, string) {
+	min, max := [3]int{x1, y1, z1}, [3]int{x2, y2, z2}
+	for i := range min {
+		if min[i] > max[i] {
+			min[i], max[i] = max[i], min[i]
+		}
+	}
+	for _, v := range w.Zones() {
+		if v.intersects(min, max) && !v.CanBuild(player) {
+			return false, v.Name
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,79 @@
+package world
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"marmalade/blocks"
+	"marmalade/classicworld"
+	"marmalade/packets/outbound"
+	"marmalade/ranks"
+)
+
+func newBuilder(t *testing.T, name string, w *World, permissions ...string) *Player {
+	rank := &ranks.Rank{Permissions: append([]string{"build", "delete", "place.*"}, permissions...)}
+	p := &Player{Username: name, World: w, Rank: rank, Authenticated: true, Writer: outbound.NewAFCBW(ioutil.Discard, time.Second)}
+	if err := AddPlayer(p); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { RemovePlayer(p) })
+	return p
+}
+
+func TestZoneBuilding(t *testing.T) {
+	w := NewWorld("zones", classicworld.New("zones", 16, 16, 16))
+	if err := w.AddZone(Zone{Name: "spawn", Min: [3]uint16{8, 8, 8}, Max: [3]uint16{4, 4, 4}, Owners: []string{"owner"}, Allow: []string{"friend"}, Deny: []string{"griefer"}}); err != nil {
+		t.Fatal(err)
+	}
+	owner, friend, stranger := newBuilder(t, "owner", w), newBuilder(t, "friend", w), newBuilder(t, "stranger", w)
+	griefer, admin := newBuilder(t, "griefer", w), newBuilder(t, "admin", w, "zone.bypass")
+
+	for _, v := range []struct {
+		player *Player
+		can    bool
+	}{{owner, true}, {friend, true}, {stranger, false}, {griefer, false}, {admin, true}} {
+		w.SetBlock(6, 6, 6, blocks.Air)
+		if ok := PlaceBlock(v.player, 6, 6, 6, 1, blocks.Stone); ok != v.can || (w.GetBlock(6, 6, 6) == blocks.Stone) != v.can {
+			t.Errorf("%v building in the zone: expected %v, got %v", v.player.Username, v.can, ok)
+		}
+	}
+	if !PlaceBlock(stranger, 9, 9, 9, 1, blocks.Stone) {
+		t.Error("building outside of the zone was refused")
+	}
+	if ok, zone := w.CanBuildIn(stranger, 0, 0, 0, 15, 4, 15); ok || zone != "spawn" {
+		t.Errorf("a cuboid touching the zone was allowed: %v %v", ok, zone)
+	}
+	if w.ZoneAt(4, 8, 6) != "spawn" || w.ZoneAt(3, 8, 6) != "" {
+		t.Error("wrong zone corners")
+	}
+
+	if err := w.EditZone("SPAWN", func(z *Zone) { z.Allow = append(z.Allow, "stranger") }); err != nil {
+		t.Fatal(err)
+	}
+	if !PlaceBlock(stranger, 6, 6, 6, 0, blocks.Air) {
+		t.Error("an allowed player couldn't build")
+	}
+	if err := w.RemoveZone("spawn"); err != nil || len(w.Zones()) != 0 {
+		t.Fatalf("failed to remove the zone: %v", err)
+	}
+}
+
+func TestZonesSaved(t *testing.T) {
+	w := NewWorld("zones", classicworld.New("zones", 16, 16, 16))
+	zone := Zone{Name: "build", Min: [3]uint16{1, 2, 3}, Max: [3]uint16{4, 5, 6}, Owners: []string{"alice"}, Deny: []string{"bob"}, MinRank: "mod"}
+	if err := w.AddZone(zone); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddZone(Zone{Name: "Build"}); err != DuplicateZoneError {
+		t.Fatalf("expected DuplicateZoneError, got %v", err)
+	}
+
+	level := classicworld.New("zones", 16, 16, 16)
+	level.Metadata = w.metadataSnapshot()
+	loaded := NewWorld("zones", level)
+	if got := loaded.Zones(); len(got) != 1 || !reflect.DeepEqual(got[0], zone) {
+		t.Fatalf("expected %+v, got %+v", zone, got)
+	}
+}